@@ -0,0 +1,187 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package broker shares an OpenDAQ device between several local processes.
+//
+// The broker owns the connection to the device and serves it over a Unix
+// socket. The commands of the clients are serialized, and each client keeps
+// its own ADC configuration: the broker configures the ADC again before a
+// read if another client has changed it.
+//
+// Client implements the godaq.Device interface plus the capture and encoder
+// methods of godaq.OpenDAQ, so a program written against godaq.Device can
+// switch between direct and brokered access without other changes. The rest
+// of the OpenDAQ methods (transactions, ramps and slew limits, output limits
+// and interlocks, the safe state, snapshots, SPI, waveforms...) are only
+// available to the broker process.
+package broker
+
+import (
+	"errors"
+	"time"
+
+	"github.com/opendaq/godaq"
+)
+
+// Name of the RPC service
+const service = "Device"
+
+// Arguments of a call. Each method uses only some of the fields.
+type Request struct {
+	N      uint
+	Value  bool
+	Volts  float32
+	Raw    int
+	Port   uint8
+	Color  godaq.Color
+	ADC    godaq.ADCConfig
+	Mode   godaq.CaptureMode
+	Period time.Duration
+
+	// GetCalib
+	IsOutput, DiffMode, SecondStage bool
+	GainId                          uint
+}
+
+// Results of a call. Each method uses only some of the fields.
+type Response struct {
+	Features       godaq.HwFeatures
+	Model, Version uint8
+	Serial         string
+	Raw            int16
+	Volts          float32
+	Value          uint8
+	Calib          godaq.Calib
+	Capture        godaq.Capture
+	Duration       time.Duration
+	Count          int32
+	Err            *Error // Error returned by the device (nil on success)
+}
+
+// Kind of error returned by a call
+type ErrorKind uint8
+
+const (
+	ErrorOther     ErrorKind = iota // Any other error: only the message is kept
+	ErrorSentinel                   // One of the error values of the godaq package
+	ErrorLimit                      // *godaq.LimitError
+	ErrorInterlock                  // *godaq.InterlockError
+	ErrorBusy                       // *godaq.BusyError
+)
+
+// Error sent to the client, with the fields needed to rebuild the godaq error.
+// Each kind uses only some of the fields.
+type Error struct {
+	Kind     ErrorKind
+	Message  string
+	Sentinel string // Message of the godaq error value (ErrorSentinel)
+
+	// ErrorLimit
+	Output          string
+	N               uint
+	Value, Min, Max float32
+
+	// ErrorInterlock
+	Name   string
+	Change godaq.Change
+	Cause  *Error
+
+	// ErrorBusy
+	Port string
+	PID  int
+}
+
+// Error values of the godaq package restored by the client
+var knownErrors = []error{
+	godaq.ErrUnknownModel, godaq.ErrInvalidLed, godaq.ErrInvalidInput,
+	godaq.ErrInvalidOutput, godaq.ErrInvalidPIO, godaq.ErrInvalidGainID,
+	godaq.ErrInvalidID, godaq.ErrInvalidPIOValue, godaq.ErrNotReady,
+	godaq.ErrChecksum, godaq.ErrInvalidLength, godaq.ErrNakReceived,
+	godaq.ErrDisconnected, godaq.ErrClosed, godaq.ErrDeviceNotFound,
+	godaq.ErrInvalidCaptureMode, godaq.ErrInvalidResolution,
+	godaq.ErrUnknownOutput, godaq.ErrInvalidSlew, godaq.ErrRampInTx,
+	godaq.ErrInvalidSPIMode, godaq.ErrInvalidWordSize, godaq.ErrInvalidSPILen,
+	godaq.ErrSignalTooLong, godaq.ErrEmptySignal, godaq.ErrInvalidPeriod,
+	godaq.ErrInvalidPoints,
+}
+
+// Convert an error of the device to the form sent to the client
+func encodeError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	e := &Error{Message: err.Error()}
+	var limit *godaq.LimitError
+	var interlock *godaq.InterlockError
+	var busy *godaq.BusyError
+	switch {
+	case errors.As(err, &limit):
+		e.Kind = ErrorLimit
+		e.Output, e.N, e.Value, e.Min, e.Max = limit.Output, limit.N, limit.Value, limit.Min, limit.Max
+	case errors.As(err, &interlock):
+		e.Kind = ErrorInterlock
+		e.Name, e.Change, e.Cause = interlock.Name, interlock.Change, encodeError(interlock.Err)
+	case errors.As(err, &busy):
+		e.Kind = ErrorBusy
+		e.Port, e.PID = busy.Port, busy.PID
+	default:
+		for _, known := range knownErrors {
+			if errors.Is(err, known) {
+				e.Kind = ErrorSentinel
+				e.Sentinel = known.Error()
+				break
+			}
+		}
+	}
+	return e
+}
+
+// Rebuild the godaq error sent by the broker
+func decodeError(e *Error) error {
+	if e == nil {
+		return nil
+	}
+	var err error
+	switch e.Kind {
+	case ErrorLimit:
+		err = &godaq.LimitError{Output: e.Output, N: e.N, Value: e.Value, Min: e.Min, Max: e.Max}
+	case ErrorInterlock:
+		err = &godaq.InterlockError{Name: e.Name, Change: e.Change, Err: decodeError(e.Cause)}
+	case ErrorBusy:
+		err = &godaq.BusyError{Port: e.Port, PID: e.PID}
+	case ErrorSentinel:
+		for _, known := range knownErrors {
+			if known.Error() == e.Sentinel {
+				err = known
+				break
+			}
+		}
+	}
+	if err == nil {
+		return errors.New(e.Message)
+	}
+	if err.Error() != e.Message {
+		// The error was wrapped with more context
+		return &wrappedError{e.Message, err}
+	}
+	return err
+}
+
+// Error with its own message that wraps a godaq error
+type wrappedError struct {
+	msg string
+	err error
+}
+
+func (e *wrappedError) Error() string { return e.msg }
+func (e *wrappedError) Unwrap() error { return e.err }
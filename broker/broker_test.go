@@ -0,0 +1,211 @@
+package broker
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/opendaq/godaq"
+	"github.com/stretchr/testify/assert"
+)
+
+// Device simulator: answers the identity and ADC commands and echoes the rest
+type fakeDevice struct {
+	sync.Mutex
+	pos      uint8
+	requests []godaq.Message
+	resp     bytes.Buffer
+}
+
+func (d *fakeDevice) Write(b []byte) (int, error) {
+	d.Lock()
+	defer d.Unlock()
+	msg := godaq.Message{Number: godaq.CommandNumber(b[2]), Body: append([]byte(nil), b[4:]...)}
+	d.requests = append(d.requests, msg)
+	d.resp.Reset()
+
+	c, ok := godaq.LookupCommand(msg.Number)
+	if !ok {
+		return 0, errors.New("Unknown command")
+	}
+	body := make([]byte, c.ResponseLen(msg.Body))
+	copy(body, msg.Body)
+	switch msg.Number {
+	case godaq.ID_CONFIG:
+		body, _ = c.Response.Encode(godaq.ModelMId, 1, 42)
+	case godaq.AIN_CFG:
+		d.pos = msg.Body[0]
+		body, _ = c.Response.Encode(100*int(d.pos), msg.Body[0], msg.Body[1], msg.Body[2], msg.Body[3])
+	case godaq.AIN:
+		body, _ = c.Response.Encode(100 * int(d.pos))
+	}
+	frame, _ := (&godaq.Message{Number: msg.Number, Body: body}).Marshal()
+	d.resp.Write(frame)
+	return len(b), nil
+}
+
+func (d *fakeDevice) Read(b []byte) (int, error) {
+	d.Lock()
+	defer d.Unlock()
+	return d.resp.Read(b)
+}
+
+func (d *fakeDevice) Close() error { return nil }
+func (d *fakeDevice) Flush() error { return nil }
+
+// Return the requests received with the given command number
+func (d *fakeDevice) sent(n godaq.CommandNumber) []godaq.Message {
+	d.Lock()
+	defer d.Unlock()
+	var list []godaq.Message
+	for _, m := range d.requests {
+		if m.Number == n {
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
+// Serve a simulated device on a temporary socket
+func newTestServer(t *testing.T) (*Server, *godaq.OpenDAQ, *fakeDevice, string) {
+	dev := &fakeDevice{}
+	daq, err := godaq.New("fake", godaq.WithTransport(dev), godaq.WithIdentityCalib())
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	dir, err := ioutil.TempDir("", "broker")
+	assert.Nil(t, err)
+	socket := filepath.Join(dir, "godaq.sock")
+
+	srv := NewServer(daq)
+	done := make(chan error, 1)
+	go func() { done <- srv.ListenAndServe(socket) }()
+	t.Cleanup(func() {
+		srv.Close()
+		assert.Nil(t, <-done)
+		daq.Close()
+		os.RemoveAll(dir)
+	})
+	return srv, daq, dev, socket
+}
+
+// Connect to a server, waiting for it to listen
+func dialTest(t *testing.T, socket string) *Client {
+	var c *Client
+	var err error
+	for i := 0; i < 100; i++ {
+		if c, err = Dial(socket); err == nil {
+			t.Cleanup(func() { c.Close() })
+			return c
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal(err)
+	return nil
+}
+
+func TestClient(t *testing.T) {
+	_, _, dev, socket := newTestServer(t)
+	c := dialTest(t, socket)
+
+	assert.Equal(t, godaq.NewModelM().GetFeatures(), c.HwFeatures)
+	model, _, serial, err := c.GetInfo()
+	assert.Nil(t, err)
+	assert.EqualValues(t, godaq.ModelMId, model)
+	assert.Equal(t, "0042", serial)
+
+	assert.Nil(t, c.SetLED(1, godaq.RED))
+	led := dev.sent(godaq.LED_W)
+	if assert.Len(t, led, 1) {
+		assert.Equal(t, []byte{byte(godaq.RED), 1}, led[0].Body)
+	}
+}
+
+func TestClientADCConfig(t *testing.T) {
+	_, _, dev, socket := newTestServer(t)
+	c1 := dialTest(t, socket)
+	c2 := dialTest(t, socket)
+
+	assert.Nil(t, c1.ConfigureADC(1, 0, 0, 20))
+	assert.Nil(t, c2.ConfigureADC(2, 0, 0, 20))
+	raw, err := c1.ReadADC()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 100, raw)
+	raw, err = c2.ReadADC()
+	assert.Nil(t, err)
+	assert.EqualValues(t, 200, raw)
+	// The ADC is configured again for each client
+	assert.Len(t, dev.sent(godaq.AIN_CFG), 4)
+}
+
+func TestClientErrors(t *testing.T) {
+	_, daq, _, socket := newTestServer(t)
+	c := dialTest(t, socket)
+
+	assert.Equal(t, godaq.ErrInvalidOutput, c.SetAnalog(9, 0))
+
+	assert.Nil(t, daq.SetOutputLimits(1, 0, 2.5))
+	err := c.SetAnalog(1, 3)
+	var limit *godaq.LimitError
+	if assert.True(t, errors.As(err, &limit)) {
+		assert.Equal(t, &godaq.LimitError{Output: "analog output", N: 1, Value: 3, Min: 0, Max: 2.5}, limit)
+	}
+
+	daq.AddInterlock("PIO 1 low", func(daq *godaq.OpenDAQ, c godaq.Change) error {
+		if c.Kind == godaq.ChangePIO && c.N == 1 {
+			return godaq.ErrInvalidPIOValue
+		}
+		return nil
+	})
+	err = c.SetPIO(1, true)
+	var interlock *godaq.InterlockError
+	if assert.True(t, errors.As(err, &interlock)) {
+		assert.Equal(t, "PIO 1 low", interlock.Name)
+		assert.Equal(t, godaq.Change{Kind: godaq.ChangePIO, N: 1, Value: true}, interlock.Change)
+	}
+	assert.True(t, errors.Is(err, godaq.ErrInvalidPIOValue))
+	assert.Equal(t, err.Error(), c.SetPIO(1, true).Error())
+
+	assert.Nil(t, daq.Close())
+	assert.Equal(t, godaq.ErrClosed, c.SetLED(1, godaq.GREEN))
+}
+
+func TestErrorEncoding(t *testing.T) {
+	for _, err := range []error{
+		&godaq.BusyError{Port: "/dev/ttyUSB0", PID: 12},
+		godaq.ErrInvalidSlew,
+		godaq.ErrInvalidSPIMode,
+	} {
+		assert.Equal(t, err, decodeError(encodeError(err)))
+	}
+	err := decodeError(encodeError(fmt.Errorf("Loading: %w", godaq.ErrEmptySignal)))
+	assert.EqualError(t, err, "Loading: Empty waveform")
+	assert.True(t, errors.Is(err, godaq.ErrEmptySignal))
+	assert.EqualError(t, decodeError(encodeError(errors.New("Other"))), "Other")
+	assert.Nil(t, decodeError(encodeError(nil)))
+}
+
+func TestListenAndServeSocket(t *testing.T) {
+	_, _, _, socket := newTestServer(t)
+	dialTest(t, socket)
+	fi, err := os.Lstat(socket)
+	assert.Nil(t, err)
+	assert.Equal(t, os.ModeSocket|0600, fi.Mode())
+
+	// Other files are not removed
+	dir, err := ioutil.TempDir("", "broker")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "file")
+	assert.Nil(t, ioutil.WriteFile(path, []byte("data"), 0644))
+	assert.NotNil(t, NewServer(nil).ListenAndServe(path))
+	b, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, "data", string(b))
+}
@@ -0,0 +1,170 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"net/rpc"
+	"time"
+
+	"github.com/opendaq/godaq"
+)
+
+// Connection to a broker. It implements godaq.Device and the capture and
+// encoder methods, which behave like the ones of godaq.OpenDAQ.
+type Client struct {
+	rpc *rpc.Client
+	godaq.HwFeatures
+}
+
+var _ godaq.Device = (*Client)(nil)
+
+// Connect to the broker listening on a Unix socket
+func Dial(socket string) (*Client, error) {
+	c, err := rpc.Dial("unix", socket)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{rpc: c}
+	var resp Response
+	if err := client.call("Features", &Request{}, &resp); err != nil {
+		c.Close()
+		return nil, err
+	}
+	client.HwFeatures = resp.Features
+	return client, nil
+}
+
+func (c *Client) call(method string, req *Request, resp *Response) error {
+	if resp == nil {
+		resp = &Response{}
+	}
+	if err := c.rpc.Call(service+"."+method, req, resp); err != nil {
+		return err
+	}
+	return decodeError(resp.Err)
+}
+
+// Close the connection to the broker. The device stays open.
+func (c *Client) Close() error {
+	return c.rpc.Close()
+}
+
+func (c *Client) GetInfo() (model, version uint8, serial string, err error) {
+	var resp Response
+	err = c.call("GetInfo", &Request{}, &resp)
+	return resp.Model, resp.Version, resp.Serial, err
+}
+
+// Return the calibration values for a given input or output.
+// The identity calibration is returned if the broker can't be reached.
+func (c *Client) GetCalib(isOutput, diffMode, secondStage bool, n, gainId uint) godaq.Calib {
+	var resp Response
+	req := &Request{IsOutput: isOutput, DiffMode: diffMode, SecondStage: secondStage, N: n, GainId: gainId}
+	if err := c.call("GetCalib", req, &resp); err != nil {
+		return godaq.Calib{Gain: 1, Offset: 0}
+	}
+	return resp.Calib
+}
+
+// Configure the ADC for this client. Other clients keep their own settings.
+func (c *Client) ConfigureADC(posInput, negInput, gainId uint, nSamples uint8) error {
+	return c.call("ConfigureADC", &Request{ADC: godaq.ADCConfig{PosInput: posInput,
+		NegInput: negInput, GainId: gainId, NSamples: nSamples}}, nil)
+}
+
+func (c *Client) ReadADC() (int16, error) {
+	var resp Response
+	err := c.call("ReadADC", &Request{}, &resp)
+	return resp.Raw, err
+}
+
+func (c *Client) ReadAnalog() (float32, error) {
+	var resp Response
+	err := c.call("ReadAnalog", &Request{}, &resp)
+	return resp.Volts, err
+}
+
+func (c *Client) SetDAC(n uint, val int) error {
+	return c.call("SetDAC", &Request{N: n, Raw: val}, nil)
+}
+
+func (c *Client) SetAnalog(n uint, val float32) error {
+	return c.call("SetAnalog", &Request{N: n, Volts: val}, nil)
+}
+
+func (c *Client) SetPIO(n uint, value bool) error {
+	return c.call("SetPIO", &Request{N: n, Value: value}, nil)
+}
+
+func (c *Client) SetPIODir(n uint, out bool) error {
+	return c.call("SetPIODir", &Request{N: n, Value: out}, nil)
+}
+
+func (c *Client) ReadPIO(n uint) (uint8, error) {
+	var resp Response
+	err := c.call("ReadPIO", &Request{N: n}, &resp)
+	return resp.Value, err
+}
+
+func (c *Client) SetPortDir(dir_port uint8) error {
+	return c.call("SetPortDir", &Request{Port: dir_port}, nil)
+}
+
+func (c *Client) ReadPort() (uint8, error) {
+	var resp Response
+	err := c.call("ReadPort", &Request{}, &resp)
+	return resp.Value, err
+}
+
+func (c *Client) SetPort(value_port uint8) error {
+	return c.call("SetPort", &Request{Port: value_port}, nil)
+}
+
+func (c *Client) SetLED(n uint, color godaq.Color) error {
+	return c.call("SetLED", &Request{N: n, Color: color}, nil)
+}
+
+func (c *Client) InitCapture(period time.Duration) error {
+	return c.call("InitCapture", &Request{Period: period}, nil)
+}
+
+func (c *Client) StopCapture() error {
+	return c.call("StopCapture", &Request{}, nil)
+}
+
+func (c *Client) GetCapture(mode godaq.CaptureMode) (time.Duration, error) {
+	var resp Response
+	err := c.call("GetCapture", &Request{Mode: mode}, &resp)
+	return resp.Duration, err
+}
+
+func (c *Client) ReadCapture() (godaq.Capture, error) {
+	var resp Response
+	err := c.call("ReadCapture", &Request{}, &resp)
+	return resp.Capture, err
+}
+
+func (c *Client) InitEncoder(resolution uint) error {
+	return c.call("InitEncoder", &Request{N: resolution}, nil)
+}
+
+func (c *Client) StopEncoder() error {
+	return c.call("StopEncoder", &Request{}, nil)
+}
+
+func (c *Client) ReadEncoder() (int32, error) {
+	var resp Response
+	err := c.call("ReadEncoder", &Request{}, &resp)
+	return resp.Count, err
+}
@@ -0,0 +1,284 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package broker
+
+import (
+	"net"
+	"net/rpc"
+	"os"
+	"sync"
+
+	"github.com/opendaq/godaq"
+)
+
+// Broker serving a device to local clients
+type Server struct {
+	// Permissions of the socket created by ListenAndServe (default: 0600,
+	// only the owner can connect)
+	SocketMode os.FileMode
+
+	daq *godaq.OpenDAQ
+	mu  sync.Mutex // Serializes the calls of all the clients
+
+	lmu      sync.Mutex
+	listener net.Listener
+	closed   bool
+}
+
+func NewServer(daq *godaq.OpenDAQ) *Server {
+	return &Server{daq: daq}
+}
+
+// Listen on a Unix socket and serve the clients until the listener fails or
+// the server is closed. A stale socket is removed before listening, but any
+// other file at the path is left alone and makes it fail. The socket is
+// removed when the listener is closed.
+func (s *Server) ListenAndServe(socket string) error {
+	if fi, err := os.Lstat(socket); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(socket)
+	}
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	mode := s.SocketMode
+	if mode == 0 {
+		mode = 0600
+	}
+	if err := os.Chmod(socket, mode); err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve the clients accepted by a listener. It returns nil once the server is
+// closed.
+func (s *Server) Serve(l net.Listener) error {
+	s.lmu.Lock()
+	if s.closed {
+		s.lmu.Unlock()
+		return nil
+	}
+	s.listener = l
+	s.lmu.Unlock()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.lmu.Lock()
+			closed := s.closed
+			s.lmu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// Stop accepting clients. The connected clients and the device are not
+// closed.
+func (s *Server) Close() error {
+	s.lmu.Lock()
+	defer s.lmu.Unlock()
+	s.closed = true
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+// Serve a single client. Each client has its own session, which holds its
+// ADC configuration.
+func (s *Server) ServeConn(conn net.Conn) {
+	srv := rpc.NewServer()
+	srv.RegisterName(service, &Session{srv: s})
+	srv.ServeConn(conn)
+}
+
+// RPC service of a client connection. Its methods are called by net/rpc.
+type Session struct {
+	srv *Server
+	in  *godaq.AnalogInput // ADC configuration of the client (nil if not set)
+}
+
+// Run f with exclusive access to the device. Its error is sent in the
+// response, so that the client can rebuild it.
+func (s *Session) do(resp *Response, f func(daq *godaq.OpenDAQ) error) error {
+	s.srv.mu.Lock()
+	defer s.srv.mu.Unlock()
+	resp.Err = encodeError(f(s.srv.daq))
+	return nil
+}
+
+func (s *Session) Features(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) error {
+		resp.Features = daq.HwFeatures
+		return nil
+	})
+}
+
+func (s *Session) GetInfo(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) (err error) {
+		resp.Model, resp.Version, resp.Serial, err = daq.GetInfo()
+		return
+	})
+}
+
+func (s *Session) GetCalib(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) error {
+		resp.Calib = daq.GetCalib(req.IsOutput, req.DiffMode, req.SecondStage, req.N, req.GainId)
+		return nil
+	})
+}
+
+func (s *Session) ConfigureADC(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) error {
+		c := req.ADC
+		if err := daq.ConfigureADC(c.PosInput, c.NegInput, c.GainId, c.NSamples); err != nil {
+			return err
+		}
+		in, err := daq.AnalogInput(c.PosInput, c.NegInput, c.GainId, c.NSamples)
+		if err == nil {
+			s.in = in
+		}
+		return err
+	})
+}
+
+// Read with the ADC configuration of the client, configuring the ADC again if
+// another client has changed it
+func (s *Session) ReadADC(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) (err error) {
+		if s.in == nil {
+			resp.Raw, err = daq.ReadADC()
+		} else {
+			resp.Raw, err = s.in.ReadRaw()
+		}
+		return
+	})
+}
+
+func (s *Session) ReadAnalog(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) (err error) {
+		if s.in == nil {
+			resp.Volts, err = daq.ReadAnalog()
+		} else {
+			resp.Volts, err = s.in.Read()
+		}
+		return
+	})
+}
+
+func (s *Session) SetDAC(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) error {
+		return daq.SetDAC(req.N, req.Raw)
+	})
+}
+
+func (s *Session) SetAnalog(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) error {
+		return daq.SetAnalog(req.N, req.Volts)
+	})
+}
+
+func (s *Session) SetPIO(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) error {
+		return daq.SetPIO(req.N, req.Value)
+	})
+}
+
+func (s *Session) SetPIODir(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) error {
+		return daq.SetPIODir(req.N, req.Value)
+	})
+}
+
+func (s *Session) ReadPIO(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) (err error) {
+		resp.Value, err = daq.ReadPIO(req.N)
+		return
+	})
+}
+
+func (s *Session) SetPortDir(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) error {
+		return daq.SetPortDir(req.Port)
+	})
+}
+
+func (s *Session) ReadPort(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) (err error) {
+		resp.Value, err = daq.ReadPort()
+		return
+	})
+}
+
+func (s *Session) SetPort(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) error {
+		return daq.SetPort(req.Port)
+	})
+}
+
+func (s *Session) SetLED(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) error {
+		return daq.SetLED(req.N, req.Color)
+	})
+}
+
+func (s *Session) InitCapture(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) error {
+		return daq.InitCapture(req.Period)
+	})
+}
+
+func (s *Session) StopCapture(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) error {
+		return daq.StopCapture()
+	})
+}
+
+func (s *Session) GetCapture(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) (err error) {
+		resp.Duration, err = daq.GetCapture(req.Mode)
+		return
+	})
+}
+
+func (s *Session) ReadCapture(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) (err error) {
+		resp.Capture, err = daq.ReadCapture()
+		return
+	})
+}
+
+func (s *Session) InitEncoder(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) error {
+		return daq.InitEncoder(req.N)
+	})
+}
+
+func (s *Session) StopEncoder(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) error {
+		return daq.StopEncoder()
+	})
+}
+
+func (s *Session) ReadEncoder(req *Request, resp *Response) error {
+	return s.do(resp, func(daq *godaq.OpenDAQ) (err error) {
+		resp.Count, err = daq.ReadEncoder()
+		return
+	})
+}
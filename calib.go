@@ -0,0 +1,113 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// Calibration file contents
+type calibFileData struct {
+	Model  uint8
+	Serial string
+	Calib  []Calib
+}
+
+// Load the calibration registers from the configured source
+func (daq *OpenDAQ) loadCalib() ([]Calib, error) {
+	calib := make([]Calib, daq.NCalibRegs)
+	switch daq.cfg.calibSource {
+	case calibIdentity:
+		for i := range calib {
+			calib[i] = Calib{1, 0}
+		}
+	case calibFile:
+		b, err := ioutil.ReadFile(daq.cfg.calibFile)
+		if err != nil {
+			return nil, err
+		}
+		var data calibFileData
+		if err := json.Unmarshal(b, &data); err != nil {
+			return nil, err
+		}
+		if data.Model != daq.info.Model || len(data.Calib) != len(calib) {
+			return nil, errors.New("The calibration file doesn't match the device model")
+		}
+		if data.Serial != "" && daq.info.Serial != "" && data.Serial != daq.info.Serial {
+			return nil, fmt.Errorf("The calibration file belongs to another device (serial %s)", data.Serial)
+		}
+		copy(calib, data.Calib)
+	default:
+		var err error
+		for i := range calib {
+			if calib[i], err = daq.readCalib(uint8(i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return calib, nil
+}
+
+// Return the calibration registers, reading them if they are loaded lazily.
+// If they can't be read, nil is returned and they are read again next time.
+// calibLock is not held while reading: the registers are read on the held
+// device and published afterwards, so a transaction can load them too.
+func (daq *OpenDAQ) calibration() []Calib {
+	daq.calibLock.Lock()
+	calib := daq.calib
+	daq.calibLock.Unlock()
+	if calib != nil || daq.cfg == nil || daq.cfg.calibSource != calibLazy {
+		return calib
+	}
+	daq.exec(PriorityRead, func(d *OpenDAQ) error {
+		// Another operation may have loaded them while this one was queued
+		d.calibLock.Lock()
+		calib = d.calib
+		d.calibLock.Unlock()
+		if calib != nil {
+			return nil
+		}
+		loaded, err := d.loadCalib()
+		if err != nil {
+			return err
+		}
+		d.calibLock.Lock()
+		d.calib = loaded
+		d.calibLock.Unlock()
+		calib = loaded
+		return nil
+	})
+	return calib
+}
+
+// Write the calibration in use to a file that can be loaded with
+// WithCalibFile
+func (daq *OpenDAQ) WriteCalibFile(path string) error {
+	// The identity changes if the device is reconnected
+	var info DevicePort
+	if err := daq.exec(PriorityRead, func(d *OpenDAQ) error {
+		info = d.info
+		return nil
+	}); err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(calibFileData{info.Model, info.Serial, daq.calibration()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0644)
+}
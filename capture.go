@@ -0,0 +1,78 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"errors"
+	"time"
+)
+
+// Part of the input signal measured by the capture unit
+type CaptureMode uint8
+
+const (
+	CaptureLow CaptureMode = iota
+	CaptureHigh
+	CapturePeriod
+)
+
+var ErrInvalidCaptureMode = errors.New("Invalid capture mode")
+
+// Result of a full capture reading
+type Capture struct {
+	Period    time.Duration
+	High, Low time.Duration
+}
+
+// Start the capture mode. The period is an estimation of the period of the
+// input signal and it's used by the device to select the timer resolution.
+func (daq *OpenDAQ) InitCapture(period time.Duration) error {
+	us := period / time.Microsecond
+	if us < 0 || us > 1<<32-1 {
+		return errors.New("Capture period out of range")
+	}
+	_, err := daq.call(CAPTURE_INIT, uint32(us))
+	return err
+}
+
+// Stop the capture mode
+func (daq *OpenDAQ) StopCapture() error {
+	_, err := daq.call(CAPTURE_STOP)
+	return err
+}
+
+// Read the length of the low cycle, the high cycle or the full period of
+// the captured signal
+func (daq *OpenDAQ) GetCapture(mode CaptureMode) (time.Duration, error) {
+	if mode > CapturePeriod {
+		return 0, ErrInvalidCaptureMode
+	}
+	vals, err := daq.call(CAPTURE_GET, mode)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(vals.mustInt("time_us")) * time.Microsecond, nil
+}
+
+// Read the period and the high and low times of the captured signal
+func (daq *OpenDAQ) ReadCapture() (c Capture, err error) {
+	if c.Period, err = daq.GetCapture(CapturePeriod); err != nil {
+		return
+	}
+	if c.High, err = daq.GetCapture(CaptureHigh); err != nil {
+		return
+	}
+	c.Low, err = daq.GetCapture(CaptureLow)
+	return
+}
@@ -0,0 +1,46 @@
+package godaq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapture(t *testing.T) {
+	daq, sim := newSimDAQ(t)
+	sim.capture = [3]uint32{300, 700, 100000}
+
+	assert.Nil(t, daq.InitCapture(100*time.Millisecond))
+	assert.Equal(t, []Message{{CAPTURE_INIT, []byte{0, 1, 0x86, 0xa0}}}, sim.sent(CAPTURE_INIT))
+
+	d, err := daq.GetCapture(CaptureHigh)
+	assert.Nil(t, err)
+	assert.Equal(t, 700*time.Microsecond, d)
+	assert.Equal(t, []Message{{CAPTURE_GET, []byte{1}}}, sim.sent(CAPTURE_GET))
+
+	c, err := daq.ReadCapture()
+	assert.Nil(t, err)
+	assert.Equal(t, Capture{100 * time.Millisecond, 700 * time.Microsecond, 300 * time.Microsecond}, c)
+	modes := []byte{}
+	for _, m := range sim.sent(CAPTURE_GET)[1:] {
+		modes = append(modes, m.Body...)
+	}
+	assert.Equal(t, []byte{2, 1, 0}, modes)
+
+	assert.Nil(t, daq.StopCapture())
+	stop := sim.sent(CAPTURE_STOP)
+	if assert.Len(t, stop, 1) {
+		assert.Empty(t, stop[0].Body)
+	}
+}
+
+func TestCaptureErrors(t *testing.T) {
+	daq, sim := newSimDAQ(t)
+	_, err := daq.GetCapture(3)
+	assert.Equal(t, ErrInvalidCaptureMode, err)
+	assert.NotNil(t, daq.InitCapture(-time.Second))
+	assert.NotNil(t, daq.InitCapture(time.Duration(1<<32)*time.Microsecond))
+	assert.Empty(t, sim.sent(CAPTURE_INIT))
+	assert.Empty(t, sim.sent(CAPTURE_GET))
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/opendaq/godaq"
+	"github.com/opendaq/godaq/broker"
+)
+
+// Open the device and serve it until the process is interrupted
+func runBroker(args []string) error {
+	fs := flag.NewFlagSet("broker", flag.ExitOnError)
+	port := fs.String("port", "", "serial port of the device")
+	serial := fs.String("serial", "", "serial number of the device (used if -port is empty)")
+	socket := fs.String("socket", "/tmp/godaq.sock", "Unix socket to listen on")
+	mode := fs.String("mode", "0600", "permissions of the socket (octal)")
+	fs.Parse(args)
+	perm, err := strconv.ParseUint(*mode, 8, 32)
+	if err != nil || perm > 0777 {
+		return fmt.Errorf("Invalid socket mode: %s", *mode)
+	}
+
+	var daq *godaq.OpenDAQ
+	if *port != "" {
+		daq, err = godaq.New(*port)
+	} else {
+		daq, err = godaq.OpenBySerial(*serial)
+	}
+	if err != nil {
+		return err
+	}
+	defer daq.Close()
+
+	srv := broker.NewServer(daq)
+	srv.SocketMode = os.FileMode(perm)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		srv.Close()
+	}()
+
+	log.Printf("serving the device on %s", *socket)
+	return srv.ListenAndServe(*socket)
+}
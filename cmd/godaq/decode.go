@@ -0,0 +1,138 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/opendaq/godaq"
+)
+
+// Decode the fields of a body, or return "" if its length doesn't match the
+// command. A body with the length of the response is decoded as a response.
+func decodeFields(m *godaq.Message) string {
+	c, ok := godaq.LookupCommand(m.Number)
+	if !ok {
+		return ""
+	}
+	vals, err := c.DecodeRequest(m.Body)
+	if len(m.Body) == c.RespLen || err != nil {
+		vals, err = c.Response.Decode(m.Body)
+	}
+	if err != nil {
+		return ""
+	}
+	return vals.String()
+}
+
+// Parse a hex dump. Whitespace, "0x" prefixes and ':' or ',' separators are
+// ignored.
+func parseHex(text string) ([]byte, error) {
+	text = strings.Replace(text, "0x", "", -1)
+	text = strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\r', '\n', ':', ',':
+			return -1
+		}
+		return r
+	}, text)
+	return hex.DecodeString(text)
+}
+
+// Print the frames of a byte stream. Returns an error if a frame is invalid.
+func decodeFrames(w io.Writer, data []byte) error {
+	var invalid bool
+	for off := 0; off < len(data); {
+		m, n, err := godaq.ReadFrame(data[off:])
+		frame := data[off : off+n]
+		dump := fmt.Sprintf("% x", frame)
+		if n > 4 {
+			dump = fmt.Sprintf("% x | % x", frame[:4], frame[4:])
+		}
+		name := m.Number.String()
+		if n < 3 {
+			name = "?"
+		} else if m.Number.Known() || m.Number.IsNAK() {
+			name = fmt.Sprintf("%s(%d)", name, uint8(m.Number))
+		}
+		line := fmt.Sprintf("%04x  %-18s %s", off, name, dump)
+		if err == nil {
+			if fields := decodeFields(&m); fields != "" {
+				line += "  " + fields
+			}
+		}
+		var flags []string
+		switch {
+		case err == godaq.ErrInvalidLength:
+			flags = append(flags, "TRUNCATED")
+		case err == godaq.ErrChecksum:
+			flags = append(flags, "BAD CHECKSUM")
+		}
+		if m.Number.IsNAK() {
+			flags = append(flags, "NAK")
+		} else if !m.Number.Known() && n >= 4 {
+			flags = append(flags, "UNKNOWN COMMAND")
+		}
+		if len(flags) > 0 {
+			line += "  [" + strings.Join(flags, ", ") + "]"
+		}
+		if err != nil {
+			invalid = true
+		}
+		fmt.Fprintln(w, line)
+		off += n
+	}
+	if invalid {
+		return errors.New("invalid frames found")
+	}
+	return nil
+}
+
+// Decode the frames read from a file or stdin
+func runDecode(args []string) error {
+	fs := flag.NewFlagSet("decode", flag.ExitOnError)
+	raw := fs.Bool("raw", false, "read raw bytes instead of a hex dump")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: godaq decode [-raw] [file]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	in := io.Reader(os.Stdin)
+	if fs.NArg() > 0 {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	data, err := ioutil.ReadAll(in)
+	if err != nil {
+		return err
+	}
+	if !*raw {
+		if data, err = parseHex(string(data)); err != nil {
+			return fmt.Errorf("invalid hex input (use -raw for binary data): %v", err)
+		}
+	}
+	return decodeFrames(os.Stdout, data)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeFrames(t *testing.T) {
+	data, err := parseHex("0x00 0x17 12:02:02:01\n00,01,01,00 00a0a000 00 63 63 00 00 0b 01 02 00")
+	assert.Nil(t, err)
+
+	var out bytes.Buffer
+	assert.Nil(t, decodeFrames(&out, data[:18]))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.Contains(t, lines[0], "LED_W(18)")
+	assert.Contains(t, lines[0], "color=2 n=1")
+	assert.Contains(t, lines[2], "[NAK]")
+	assert.Contains(t, lines[3], "[UNKNOWN COMMAND]")
+
+	out.Reset()
+	assert.NotNil(t, decodeFrames(&out, data[18:]))
+	assert.Contains(t, out.String(), "AIN(1)")
+	assert.Contains(t, out.String(), "[TRUNCATED]")
+}
+
+func TestDecodeFields(t *testing.T) {
+	data, _ := parseHex("02 00 01 02 ff fe")
+	var out bytes.Buffer
+	decodeFrames(&out, data)
+	assert.Contains(t, out.String(), "value=-2")
+}
@@ -0,0 +1,55 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command godaq provides tools for OpenDAQ devices.
+//
+// Usage:
+//
+//	godaq broker [-port path | -serial number] [-socket path]
+//	godaq decode [-raw] [file]
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: godaq <command> [arguments]
+
+Commands:
+	broker  share a device with local clients over a Unix socket
+	decode  print the protocol frames of a hex dump or raw capture
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "broker":
+		err = runBroker(args)
+	case "decode":
+		err = runDecode(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "godaq: unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "godaq:", err)
+		os.Exit(1)
+	}
+}
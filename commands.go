@@ -0,0 +1,334 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Type of a field of a command body. Numbers are big endian.
+type FieldKind uint8
+
+const (
+	Uint8 FieldKind = iota
+	Uint16
+	Int16
+	Uint32
+	Int32
+	Bytes // Rest of the body
+)
+
+// Return the size of the field, or 0 for Bytes
+func (k FieldKind) Size() int {
+	switch k {
+	case Uint8:
+		return 1
+	case Uint16, Int16:
+		return 2
+	case Uint32, Int32:
+		return 4
+	}
+	return 0
+}
+
+type Field struct {
+	Name string
+	Kind FieldKind
+}
+
+// Fields of a request or response body, in order. Only the last field can
+// be of kind Bytes.
+type Layout []Field
+
+// Return the size of the fixed-size fields and whether the layout ends with
+// a Bytes field
+func (l Layout) Size() (size int, variable bool) {
+	for _, f := range l {
+		size += f.Kind.Size()
+		variable = variable || f.Kind == Bytes
+	}
+	return
+}
+
+// Check if a body of n bytes can have this layout
+func (l Layout) Matches(n int) bool {
+	size, variable := l.Size()
+	return n == size || (variable && n > size)
+}
+
+// Encode the body. Numeric fields take integer or bool arguments and Bytes
+// fields take a []byte.
+func (l Layout) Encode(args ...interface{}) ([]byte, error) {
+	if len(args) != len(l) {
+		return nil, fmt.Errorf("Expected %d fields, got %d", len(l), len(args))
+	}
+	var b []byte
+	for i, f := range l {
+		if f.Kind == Bytes {
+			data, ok := args[i].([]byte)
+			if !ok {
+				return nil, fmt.Errorf("Field %s: expected []byte, got %T", f.Name, args[i])
+			}
+			b = append(b, data...)
+			continue
+		}
+		var v uint64
+		switch a := reflect.ValueOf(args[i]); a.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			v = uint64(a.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			v = a.Uint()
+		case reflect.Bool:
+			v = uint64(boolToByte(a.Bool()))
+		default:
+			return nil, fmt.Errorf("Field %s: expected a number, got %T", f.Name, args[i])
+		}
+		switch f.Kind.Size() {
+		case 1:
+			b = append(b, byte(v))
+		case 2:
+			b = append(b, 0, 0)
+			binary.BigEndian.PutUint16(b[len(b)-2:], uint16(v))
+		case 4:
+			b = append(b, 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(b[len(b)-4:], uint32(v))
+		}
+	}
+	return b, nil
+}
+
+// Decoded field
+type Value struct {
+	Field
+	Int   int64  // Value of a numeric field
+	Bytes []byte // Value of a Bytes field
+}
+
+type Values []Value
+
+// Return the value of a numeric field and whether it exists
+func (vs Values) Int(name string) (int64, bool) {
+	for _, v := range vs {
+		if v.Name == name {
+			return v.Int, true
+		}
+	}
+	return 0, false
+}
+
+// Return the value of a Bytes field and whether it exists
+func (vs Values) Bytes(name string) ([]byte, bool) {
+	for _, v := range vs {
+		if v.Name == name {
+			return v.Bytes, true
+		}
+	}
+	return nil, false
+}
+
+// Return the value of a numeric field of a response decoded with the command
+// table. A missing field is a bug in the caller, so it panics.
+func (vs Values) mustInt(name string) int64 {
+	v, ok := vs.Int(name)
+	if !ok {
+		panic("godaq: no field " + name + " in the response")
+	}
+	return v
+}
+
+// Return the value of a Bytes field, panicking if it doesn't exist
+func (vs Values) mustBytes(name string) []byte {
+	b, ok := vs.Bytes(name)
+	if !ok {
+		panic("godaq: no field " + name + " in the response")
+	}
+	return b
+}
+
+// Format the values as name=value pairs
+func (vs Values) String() string {
+	parts := make([]string, len(vs))
+	for i, v := range vs {
+		if v.Kind == Bytes {
+			parts[i] = fmt.Sprintf("%s=[% x]", v.Name, v.Bytes)
+		} else {
+			parts[i] = fmt.Sprintf("%s=%d", v.Name, v.Int)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Decode a body
+func (l Layout) Decode(b []byte) (Values, error) {
+	if !l.Matches(len(b)) {
+		return nil, ErrInvalidLength
+	}
+	vs := make(Values, len(l))
+	for i, f := range l {
+		vs[i].Field = f
+		switch f.Kind {
+		case Uint8:
+			vs[i].Int = int64(b[0])
+		case Uint16:
+			vs[i].Int = int64(binary.BigEndian.Uint16(b))
+		case Int16:
+			vs[i].Int = int64(int16(binary.BigEndian.Uint16(b)))
+		case Uint32:
+			vs[i].Int = int64(binary.BigEndian.Uint32(b))
+		case Int32:
+			vs[i].Int = int64(int32(binary.BigEndian.Uint32(b)))
+		case Bytes:
+			vs[i].Bytes = b
+		}
+		b = b[f.Kind.Size():]
+	}
+	return vs, nil
+}
+
+// Descriptor of a command of the protocol
+type Command struct {
+	Name     string
+	Number   CommandNumber
+	Requests []Layout // Accepted request layouts, with different numbers of fields
+	Response Layout
+	RespLen  int // Length of the response body, or -1 if it's the request length
+}
+
+// Return the request layout with the given number of fields
+func (c *Command) request(nFields int) (Layout, error) {
+	for _, l := range c.Requests {
+		if len(l) == nFields {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("%s: no request with %d fields", c.Name, nFields)
+}
+
+// Encode a request body with the layout matching the number of arguments
+func (c *Command) EncodeRequest(args ...interface{}) ([]byte, error) {
+	l, err := c.request(len(args))
+	if err != nil {
+		return nil, err
+	}
+	return l.Encode(args...)
+}
+
+// Decode a request body with the first layout matching its length
+func (c *Command) DecodeRequest(b []byte) (Values, error) {
+	for _, l := range c.Requests {
+		if l.Matches(len(b)) {
+			return l.Decode(b)
+		}
+	}
+	return nil, ErrInvalidLength
+}
+
+// Return the length of the response body for a request body
+func (c *Command) ResponseLen(req []byte) int {
+	if c.RespLen < 0 {
+		return len(req)
+	}
+	return c.RespLen
+}
+
+func u8(name string) Field  { return Field{name, Uint8} }
+func u16(name string) Field { return Field{name, Uint16} }
+func i16(name string) Field { return Field{name, Int16} }
+func u32(name string) Field { return Field{name, Uint32} }
+func i32(name string) Field { return Field{name, Int32} }
+
+// Commands of the protocol. Commands without request layouts are only known
+// by their name.
+var commands = map[CommandNumber]*Command{}
+
+func init() {
+	for _, c := range []Command{
+		{"AIN", AIN, []Layout{{}}, Layout{i16("value")}, 2},
+		{"AIN_CFG", AIN_CFG, []Layout{{u8("pos"), u8("neg"), u8("gain"), u8("nsamples")}},
+			Layout{i16("value"), u8("pos"), u8("neg"), u8("gain"), u8("nsamples")}, 6},
+		{"PIO", PIO, []Layout{{u8("n")}, {u8("n"), u8("value")}}, Layout{u8("n"), u8("value")}, 2},
+		{"AIN_ALL", AIN_ALL, nil, nil, 0},
+		{"PIO_DIR", PIO_DIR, []Layout{{u8("n"), u8("output")}}, Layout{u8("n"), u8("output")}, 2},
+		{"PORT", PORT, []Layout{{}, {u8("value")}}, Layout{u8("value")}, 1},
+		{"PORT_DIR", PORT_DIR, []Layout{{u8("dir")}}, Layout{u8("dir")}, 1},
+		{"SET_DAC", SET_DAC, []Layout{{i16("value"), u8("n")}}, Layout{i16("value"), u8("n")}, 3},
+		{"CAPTURE_INIT", CAPTURE_INIT, []Layout{{u32("period_us")}}, Layout{}, 0},
+		{"CAPTURE_STOP", CAPTURE_STOP, []Layout{{}}, Layout{}, 0},
+		{"CAPTURE_GET", CAPTURE_GET, []Layout{{u8("mode")}}, Layout{u8("mode"), u32("time_us")}, 5},
+		{"LED_W", LED_W, []Layout{{u8("color"), u8("n")}}, Layout{u8("color"), u8("n")}, 2},
+		{"BURST_CREATE", BURST_CREATE, []Layout{{u32("period_us")}}, Layout{u32("period_us")}, 4},
+		{"CHANNEL_CFG", CHANNEL_CFG,
+			[]Layout{{u8("number"), u8("mode"), u8("pinput"), u8("ninput"), u8("gain"), u8("nsamples")}},
+			Layout{u8("number"), u8("mode"), u8("pinput"), u8("ninput"), u8("gain"), u8("nsamples")}, 6},
+		{"SIGNAL_LOAD", SIGNAL_LOAD, []Layout{{i16("offset"), {"data", Bytes}}}, Layout{{"data", Bytes}}, 3},
+		{"SET_ANALOG", SET_ANALOG, nil, nil, 0},
+		{"SPISW_CONFIG", SPISW_CONFIG, []Layout{{u8("cpol"), u8("cpha")}}, Layout{u8("cpol"), u8("cpha")}, 2},
+		{"SPISW_SETUP", SPISW_SETUP, []Layout{{u8("nbytes"), u8("sck"), u8("mosi"), u8("miso")}},
+			Layout{u8("nbytes"), u8("sck"), u8("mosi"), u8("miso")}, 4},
+		{"SPISW_TRANSFER", SPISW_TRANSFER, []Layout{{{"data", Bytes}}}, Layout{{"data", Bytes}}, -1},
+		{"CHANNEL_SETUP", CHANNEL_SETUP, []Layout{{u8("number"), u16("npoints"), u8("continuous")}},
+			Layout{u8("number"), u16("npoints"), u8("continuous")}, 4},
+		{"GET_CALIB", GET_CALIB, []Layout{{u8("reg")}}, Layout{u8("reg"), i16("gain"), i16("offset")}, 5},
+		{"ID_CONFIG", ID_CONFIG, []Layout{{}, {u32("id")}}, Layout{u8("model"), u8("version"), u32("serial")}, 6},
+		{"GET_AIN_CFG", GET_AIN_CFG, nil, nil, 0},
+		{"ENCODER_INIT", ENCODER_INIT, []Layout{{u16("resolution")}}, Layout{}, 0},
+		{"ENCODER_STOP", ENCODER_STOP, []Layout{{}}, Layout{}, 0},
+		{"ENCODER_GET", ENCODER_GET, []Layout{{}}, Layout{i32("position")}, 4},
+		{"STREAM_START", STREAM_START, []Layout{{}}, Layout{}, 0},
+		{"STREAM_STOP", STREAM_STOP, []Layout{{}}, Layout{}, 0},
+	} {
+		c := c
+		commands[c.Number] = &c
+	}
+}
+
+// Return the descriptor of a command
+func LookupCommand(n CommandNumber) (*Command, bool) {
+	c, ok := commands[n]
+	return c, ok
+}
+
+// Return the descriptors of all the commands sorted by number
+func Commands() []*Command {
+	list := make([]*Command, 0, len(commands))
+	for _, c := range commands {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Number < list[j].Number })
+	return list
+}
+
+// Send a command of the table and decode its response. The request layout
+// is chosen by the number of arguments.
+func (daq *OpenDAQ) call(n CommandNumber, args ...interface{}) (Values, error) {
+	c, ok := commands[n]
+	if !ok {
+		return nil, fmt.Errorf("Unknown command %s", n)
+	}
+	body, err := c.EncodeRequest(args...)
+	if err != nil {
+		return nil, err
+	}
+	r, err := daq.sendCommand(&Message{n, body}, c.ResponseLen(body))
+	if err != nil {
+		return nil, err
+	}
+	b, _ := ioutil.ReadAll(r)
+	return c.Response.Decode(b)
+}
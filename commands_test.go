@@ -0,0 +1,120 @@
+package godaq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Return sample arguments for a layout and the values they decode to
+func sampleArgs(l Layout) ([]interface{}, Values) {
+	args := make([]interface{}, len(l))
+	vals := make(Values, len(l))
+	for i, f := range l {
+		vals[i].Field = f
+		if f.Kind == Bytes {
+			args[i] = []byte{0xca, 0xfe}
+			vals[i].Bytes = []byte{0xca, 0xfe}
+			continue
+		}
+		v := int64(i + 1)
+		if f.Kind == Int16 || f.Kind == Int32 {
+			v = -v
+		}
+		args[i] = v
+		vals[i].Int = v
+	}
+	return args, vals
+}
+
+func TestCommandTable(t *testing.T) {
+	names := make(map[string]bool)
+	for _, c := range Commands() {
+		assert.False(t, names[c.Name], c.Name)
+		names[c.Name] = true
+		assert.Equal(t, c.Name, c.Number.String())
+		if c.Requests == nil {
+			continue
+		}
+
+		arity := make(map[int]bool)
+		for _, l := range c.Requests {
+			assert.False(t, arity[len(l)], "%s: ambiguous request layouts", c.Name)
+			arity[len(l)] = true
+
+			args, want := sampleArgs(l)
+			b, err := c.EncodeRequest(args...)
+			assert.Nil(t, err, c.Name)
+			size, _ := l.Size()
+			assert.True(t, len(b) >= size, c.Name)
+			got, err := l.Decode(b)
+			assert.Nil(t, err, c.Name)
+			assert.Equal(t, want, got, c.Name)
+
+			size, variable := c.Response.Size()
+			if variable {
+				assert.True(t, c.ResponseLen(b) >= size, c.Name)
+			} else {
+				assert.Equal(t, size, c.ResponseLen(b), c.Name)
+			}
+		}
+
+		args, want := sampleArgs(c.Response)
+		b, err := c.Response.Encode(args...)
+		assert.Nil(t, err, c.Name)
+		got, err := c.Response.Decode(b)
+		assert.Nil(t, err, c.Name)
+		assert.Equal(t, want, got, c.Name)
+	}
+}
+
+func TestLayout(t *testing.T) {
+	l := Layout{i16("value"), u8("n")}
+	b, err := l.Encode(-2, uint(3))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0xff, 0xfe, 3}, b)
+
+	vals, err := l.Decode(b)
+	assert.Nil(t, err)
+	v, ok := vals.Int("value")
+	assert.True(t, ok)
+	assert.Equal(t, int64(-2), v)
+	assert.Equal(t, int64(3), vals.mustInt("n"))
+	assert.Equal(t, "value=-2 n=3", vals.String())
+	_, ok = vals.Int("vaule")
+	assert.False(t, ok)
+	_, ok = vals.Bytes("value")
+	assert.True(t, ok)
+	assert.Panics(t, func() { vals.mustInt("vaule") })
+
+	_, err = l.Decode(b[:2])
+	assert.Equal(t, ErrInvalidLength, err)
+	_, err = l.Encode(1)
+	assert.NotNil(t, err)
+	_, err = l.Encode("a", 1)
+	assert.NotNil(t, err)
+
+	b, err = Layout{u8("n"), {"data", Bytes}}.Encode(true, []byte{1, 2})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{1, 1, 2}, b)
+}
+
+func TestCall(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	assert.Nil(t, daq.SetPIO(2, true))
+	v, err := daq.ReadPIO(2)
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(1), v)
+	assert.Equal(t, []Message{{PIO, []byte{2, 1}}, {PIO, []byte{2}}}, sim.sent(PIO))
+
+	_, err = daq.call(LED_W, 1)
+	assert.NotNil(t, err)
+}
+
+func TestCallUnknownCommand(t *testing.T) {
+	daq, sim := newSimDAQ(t)
+	n := len(sim.requests)
+	_, err := daq.call(250)
+	assert.EqualError(t, err, "Unknown command CMD(250)")
+	assert.Len(t, sim.requests, n)
+}
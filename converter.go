@@ -68,6 +68,26 @@ func (dac *DAC) FromVolts(v float32, cal Calib) int {
 	return dac.clampValue(val)
 }
 
+// Convert a DAC value to a voltage (inverse of FromVolts)
+func (dac *DAC) ToVolts(val int, cal Calib) float32 {
+	min, max := dac.bitRange()
+
+	var baseGain float32
+	if dac.Signed {
+		baseGain = dac.VMax / float32(max+1)
+	} else {
+		baseGain = (dac.VMax - dac.VMin) / float32(max-min+1)
+	}
+
+	if dac.Invert {
+		baseGain = -baseGain
+	}
+	if !dac.Signed {
+		val += int(dac.VMin / baseGain)
+	}
+	return float32(val)*baseGain*cal.Gain + cal.Offset
+}
+
 // Analog-to-digital converter
 type ADC struct {
 	Bits       uint
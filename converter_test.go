@@ -60,6 +60,22 @@ func TestFromVoltsUnsigned(t *testing.T) {
 	assert.Equal(t, 4095, dac.FromVolts(10.0, Calib{1, 0}))
 }
 
+func TestDACToVolts(t *testing.T) {
+	dac := DAC{Bits: 16, Signed: true, VMin: -4.096, VMax: 4.096}
+	assert.Equal(t, float32(0), dac.ToVolts(0, Calib{1, 0}))
+	assert.Equal(t, float32(2.048), dac.ToVolts(16384, Calib{1, 0}))
+	assert.Equal(t, float32(-4.096), dac.ToVolts(-32768, Calib{1, 0}))
+
+	dac = DAC{Bits: 12, VMin: -4.096, VMax: 4.096}
+	assert.Equal(t, float32(-4.096), dac.ToVolts(0, Calib{1, 0}))
+	assert.Equal(t, float32(2.048), dac.ToVolts(3072, Calib{1, 0}))
+
+	cal := Calib{1.01, 0.02}
+	for _, v := range []float32{-3, -0.5, 0, 1.25, 4} {
+		assert.InDelta(t, v, dac.ToVolts(dac.FromVolts(v, cal), cal), 0.002)
+	}
+}
+
 func TestToVoltsSigned(t *testing.T) {
 	gains := []float32{1, 2, 4, 8}
 	adc := ADC{Bits: 12, Signed: true, VMin: -4.096, VMax: 4.096, Gains: gains}
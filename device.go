@@ -0,0 +1,40 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+// Device is the common method set of OpenDAQ and the types that can take its
+// place, such as the broker client or the fake of the godaqtest package.
+type Device interface {
+	GetInfo() (model, version uint8, serial string, err error)
+	GetCalib(isOutput, diffMode, secondStage bool, n, gainId uint) Calib
+
+	ConfigureADC(posInput, negInput, gainId uint, nSamples uint8) error
+	ReadADC() (int16, error)
+	ReadAnalog() (float32, error)
+
+	SetDAC(n uint, val int) error
+	SetAnalog(n uint, val float32) error
+
+	SetPIO(n uint, value bool) error
+	SetPIODir(n uint, out bool) error
+	ReadPIO(n uint) (uint8, error)
+	SetPortDir(dir_port uint8) error
+	ReadPort() (uint8, error)
+	SetPort(value_port uint8) error
+
+	SetLED(n uint, c Color) error
+	Close() error
+}
+
+var _ Device = (*OpenDAQ)(nil)
@@ -0,0 +1,107 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"errors"
+)
+
+var ErrInvalidResolution = errors.New("Invalid encoder resolution")
+
+// Start the encoder mode. The resolution is the number of counts per turn
+// (0 to 65535). The device counter wraps around after that number of counts.
+func (daq *OpenDAQ) InitEncoder(resolution uint) error {
+	if resolution > 0xffff {
+		return ErrInvalidResolution
+	}
+	_, err := daq.call(ENCODER_INIT, resolution)
+	return err
+}
+
+// Stop the encoder mode
+func (daq *OpenDAQ) StopEncoder() error {
+	_, err := daq.call(ENCODER_STOP)
+	return err
+}
+
+// Read the current encoder position in counts
+func (daq *OpenDAQ) ReadEncoder() (int32, error) {
+	vals, err := daq.call(ENCODER_GET)
+	if err != nil {
+		return 0, err
+	}
+	return int32(vals.mustInt("position")), nil
+}
+
+// Accumulate encoder readings into an absolute position.
+// The counter of the device wraps around after Resolution counts, so the
+// readings must be frequent enough for the encoder to move less than half a
+// turn between them.
+type EncoderTracker struct {
+	Resolution    uint    // Counts per turn (0 if the counter does not wrap)
+	UnitsPerCount float64 // Distance travelled per count
+
+	last    int32
+	total   int64
+	started bool
+}
+
+func NewEncoderTracker(resolution uint, unitsPerCount float64) *EncoderTracker {
+	return &EncoderTracker{Resolution: resolution, UnitsPerCount: unitsPerCount}
+}
+
+// Add a new reading and return the absolute position in counts
+func (t *EncoderTracker) Update(count int32) int64 {
+	if !t.started {
+		t.started = true
+		t.last = count
+		t.total = int64(count)
+		return t.total
+	}
+	// the int32 conversion handles the overflow of a non-wrapping counter
+	delta := int64(int32(count - t.last))
+	if res := int64(t.Resolution); res > 0 {
+		if delta > res/2 {
+			delta -= res
+		} else if delta < -res/2 {
+			delta += res
+		}
+	}
+	t.last = count
+	t.total += delta
+	return t.total
+}
+
+// Reset the absolute position to zero at the last reading
+func (t *EncoderTracker) Reset() {
+	t.total = 0
+}
+
+// Return the absolute position in counts
+func (t *EncoderTracker) Counts() int64 {
+	return t.total
+}
+
+// Return the absolute position in degrees
+func (t *EncoderTracker) Angle() float64 {
+	if t.Resolution == 0 {
+		return 0
+	}
+	return float64(t.total) * 360 / float64(t.Resolution)
+}
+
+// Return the absolute position in the units given by UnitsPerCount
+func (t *EncoderTracker) Distance() float64 {
+	return float64(t.total) * t.UnitsPerCount
+}
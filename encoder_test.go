@@ -0,0 +1,34 @@
+package godaq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoderTrackerWrap(t *testing.T) {
+	tr := NewEncoderTracker(100, 0.5)
+	assert.EqualValues(t, 90, tr.Update(90))
+	// forward across the wrap point
+	assert.EqualValues(t, 110, tr.Update(10))
+	assert.EqualValues(t, 130, tr.Update(30))
+	// backwards across the wrap point
+	assert.EqualValues(t, 95, tr.Update(95))
+	assert.EqualValues(t, 75, tr.Update(75))
+	assert.Equal(t, 270.0, tr.Angle())
+	assert.Equal(t, 37.5, tr.Distance())
+
+	tr.Reset()
+	assert.EqualValues(t, -5, tr.Update(70))
+}
+
+func TestEncoderTrackerNoWrap(t *testing.T) {
+	tr := NewEncoderTracker(0, 1)
+	tr.Update(-10)
+	assert.EqualValues(t, 990, tr.Update(990))
+	assert.Equal(t, 0.0, tr.Angle())
+
+	tr = NewEncoderTracker(0, 1)
+	tr.Update(2147483640)
+	assert.EqualValues(t, 2147483650, tr.Update(-2147483646))
+}
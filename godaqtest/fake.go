@@ -0,0 +1,235 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package godaqtest provides a programmable godaq.Device for tests.
+//
+// The fake records every call. Its responses are scripted by setting the
+// function fields; a nil field returns zero values and no error.
+//
+//	fake := &godaqtest.Fake{ReadAnalogFunc: godaqtest.Readings(1.5, 2.5)}
+//	run(fake)
+//	calls := fake.CallsTo("SetAnalog")
+package godaqtest
+
+import (
+	"sync"
+
+	"github.com/opendaq/godaq"
+)
+
+// Call made to the fake
+type Call struct {
+	Method string
+	Args   []interface{}
+}
+
+// Fake device. It is safe for concurrent use.
+type Fake struct {
+	mu    sync.Mutex
+	calls []Call
+
+	// Returned by GetInfo if GetInfoFunc is nil
+	Model, Version uint8
+	Serial         string
+
+	GetInfoFunc      func() (model, version uint8, serial string, err error)
+	GetCalibFunc     func(isOutput, diffMode, secondStage bool, n, gainId uint) godaq.Calib
+	ConfigureADCFunc func(posInput, negInput, gainId uint, nSamples uint8) error
+	ReadADCFunc      func() (int16, error)
+	ReadAnalogFunc   func() (float32, error)
+	SetDACFunc       func(n uint, val int) error
+	SetAnalogFunc    func(n uint, val float32) error
+	SetPIOFunc       func(n uint, value bool) error
+	SetPIODirFunc    func(n uint, out bool) error
+	ReadPIOFunc      func(n uint) (uint8, error)
+	SetPortDirFunc   func(dir_port uint8) error
+	ReadPortFunc     func() (uint8, error)
+	SetPortFunc      func(value_port uint8) error
+	SetLEDFunc       func(n uint, c godaq.Color) error
+	CloseFunc        func() error
+}
+
+var _ godaq.Device = (*Fake)(nil)
+
+func (f *Fake) record(method string, args ...interface{}) {
+	f.mu.Lock()
+	f.calls = append(f.calls, Call{method, args})
+	f.mu.Unlock()
+}
+
+// Return all the calls made so far, in order
+func (f *Fake) Calls() []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Call(nil), f.calls...)
+}
+
+// Return the calls made to a given method, in order
+func (f *Fake) CallsTo(method string) []Call {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var calls []Call
+	for _, c := range f.calls {
+		if c.Method == method {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Forget the recorded calls
+func (f *Fake) Reset() {
+	f.mu.Lock()
+	f.calls = nil
+	f.mu.Unlock()
+}
+
+func (f *Fake) GetInfo() (model, version uint8, serial string, err error) {
+	f.record("GetInfo")
+	if f.GetInfoFunc != nil {
+		return f.GetInfoFunc()
+	}
+	return f.Model, f.Version, f.Serial, nil
+}
+
+// Return the identity calibration if GetCalibFunc is nil
+func (f *Fake) GetCalib(isOutput, diffMode, secondStage bool, n, gainId uint) godaq.Calib {
+	f.record("GetCalib", isOutput, diffMode, secondStage, n, gainId)
+	if f.GetCalibFunc != nil {
+		return f.GetCalibFunc(isOutput, diffMode, secondStage, n, gainId)
+	}
+	return godaq.Calib{Gain: 1, Offset: 0}
+}
+
+func (f *Fake) ConfigureADC(posInput, negInput, gainId uint, nSamples uint8) error {
+	f.record("ConfigureADC", posInput, negInput, gainId, nSamples)
+	if f.ConfigureADCFunc != nil {
+		return f.ConfigureADCFunc(posInput, negInput, gainId, nSamples)
+	}
+	return nil
+}
+
+func (f *Fake) ReadADC() (int16, error) {
+	f.record("ReadADC")
+	if f.ReadADCFunc != nil {
+		return f.ReadADCFunc()
+	}
+	return 0, nil
+}
+
+func (f *Fake) ReadAnalog() (float32, error) {
+	f.record("ReadAnalog")
+	if f.ReadAnalogFunc != nil {
+		return f.ReadAnalogFunc()
+	}
+	return 0, nil
+}
+
+func (f *Fake) SetDAC(n uint, val int) error {
+	f.record("SetDAC", n, val)
+	if f.SetDACFunc != nil {
+		return f.SetDACFunc(n, val)
+	}
+	return nil
+}
+
+func (f *Fake) SetAnalog(n uint, val float32) error {
+	f.record("SetAnalog", n, val)
+	if f.SetAnalogFunc != nil {
+		return f.SetAnalogFunc(n, val)
+	}
+	return nil
+}
+
+func (f *Fake) SetPIO(n uint, value bool) error {
+	f.record("SetPIO", n, value)
+	if f.SetPIOFunc != nil {
+		return f.SetPIOFunc(n, value)
+	}
+	return nil
+}
+
+func (f *Fake) SetPIODir(n uint, out bool) error {
+	f.record("SetPIODir", n, out)
+	if f.SetPIODirFunc != nil {
+		return f.SetPIODirFunc(n, out)
+	}
+	return nil
+}
+
+func (f *Fake) ReadPIO(n uint) (uint8, error) {
+	f.record("ReadPIO", n)
+	if f.ReadPIOFunc != nil {
+		return f.ReadPIOFunc(n)
+	}
+	return 0, nil
+}
+
+func (f *Fake) SetPortDir(dir_port uint8) error {
+	f.record("SetPortDir", dir_port)
+	if f.SetPortDirFunc != nil {
+		return f.SetPortDirFunc(dir_port)
+	}
+	return nil
+}
+
+func (f *Fake) ReadPort() (uint8, error) {
+	f.record("ReadPort")
+	if f.ReadPortFunc != nil {
+		return f.ReadPortFunc()
+	}
+	return 0, nil
+}
+
+func (f *Fake) SetPort(value_port uint8) error {
+	f.record("SetPort", value_port)
+	if f.SetPortFunc != nil {
+		return f.SetPortFunc(value_port)
+	}
+	return nil
+}
+
+func (f *Fake) SetLED(n uint, c godaq.Color) error {
+	f.record("SetLED", n, c)
+	if f.SetLEDFunc != nil {
+		return f.SetLEDFunc(n, c)
+	}
+	return nil
+}
+
+func (f *Fake) Close() error {
+	f.record("Close")
+	if f.CloseFunc != nil {
+		return f.CloseFunc()
+	}
+	return nil
+}
+
+// Return a ReadAnalogFunc that returns the given values in order. The last
+// value is repeated once the others have been returned.
+func Readings(values ...float32) func() (float32, error) {
+	var mu sync.Mutex
+	i := 0
+	return func() (float32, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(values) == 0 {
+			return 0, nil
+		}
+		v := values[i]
+		if i < len(values)-1 {
+			i++
+		}
+		return v, nil
+	}
+}
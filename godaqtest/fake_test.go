@@ -0,0 +1,68 @@
+package godaqtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/opendaq/godaq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFakeDefaults(t *testing.T) {
+	var d godaq.Device = &Fake{Model: 1, Version: 140, Serial: "ODM08100"}
+
+	model, version, serial, err := d.GetInfo()
+	assert.Nil(t, err)
+	assert.Equal(t, uint8(1), model)
+	assert.Equal(t, uint8(140), version)
+	assert.Equal(t, "ODM08100", serial)
+	assert.Equal(t, godaq.Calib{Gain: 1, Offset: 0}, d.GetCalib(false, false, false, 1, 0))
+
+	v, err := d.ReadAnalog()
+	assert.Nil(t, err)
+	assert.Equal(t, float32(0), v)
+	assert.Nil(t, d.SetAnalog(1, 2.5))
+}
+
+func TestFakeScript(t *testing.T) {
+	errBusy := errors.New("Busy")
+	f := &Fake{
+		ReadAnalogFunc: Readings(1, 2, 3),
+		SetPIOFunc: func(n uint, value bool) error {
+			if n == 6 {
+				return errBusy
+			}
+			return nil
+		},
+	}
+
+	var got []float32
+	for i := 0; i < 4; i++ {
+		v, err := f.ReadAnalog()
+		assert.Nil(t, err)
+		got = append(got, v)
+	}
+	assert.Equal(t, []float32{1, 2, 3, 3}, got)
+
+	assert.Nil(t, f.SetPIO(1, true))
+	assert.Equal(t, errBusy, f.SetPIO(6, false))
+}
+
+func TestFakeCalls(t *testing.T) {
+	f := &Fake{}
+	f.ConfigureADC(1, 0, 2, 20)
+	f.SetAnalog(1, 1.25)
+	f.ReadAnalog()
+	f.SetAnalog(2, -1)
+
+	assert.Equal(t, []Call{
+		{"ConfigureADC", []interface{}{uint(1), uint(0), uint(2), uint8(20)}},
+		{"SetAnalog", []interface{}{uint(1), float32(1.25)}},
+		{"ReadAnalog", nil},
+		{"SetAnalog", []interface{}{uint(2), float32(-1)}},
+	}, f.Calls())
+	assert.Len(t, f.CallsTo("SetAnalog"), 2)
+
+	f.Reset()
+	assert.Empty(t, f.Calls())
+}
@@ -0,0 +1,89 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+// Configure the ADC and read a value in volts as a single operation, so that
+// concurrent reads of other channels can't interfere. The ADC is only
+// configured if its current configuration differs.
+func (daq *OpenDAQ) ReadChannel(posInput, negInput, gainId uint, nSamples uint8) (float32, error) {
+	cfg := ADCConfig{posInput, negInput, gainId, nSamples}
+	if err := daq.checkADCConfig(cfg); err != nil {
+		return 0, err
+	}
+	val, err := daq.readChannel(cfg)
+	if err != nil {
+		return 0, err
+	}
+	return daq.adcToVolts(int(val), cfg), nil
+}
+
+// Read a raw value with the given ADC configuration
+func (daq *OpenDAQ) readChannel(cfg ADCConfig) (val int16, err error) {
+	err = daq.exec(PriorityRead, func(d *OpenDAQ) error {
+		d.stateLock.Lock()
+		configured := d.adcKnown && d.adc == cfg
+		d.stateLock.Unlock()
+		if !configured {
+			if err := d.configureADC(cfg); err != nil {
+				return err
+			}
+		}
+		var e error
+		val, _, e = d.readADC()
+		return e
+	})
+	return
+}
+
+// Analog input with its own ADC configuration and calibration. Reads from
+// different inputs can be done concurrently.
+type AnalogInput struct {
+	daq        *OpenDAQ
+	cfg        ADCConfig
+	cal1, cal2 Calib
+}
+
+// Return a handle for reading an analog input with the given configuration
+func (daq *OpenDAQ) AnalogInput(posInput, negInput, gainId uint, nSamples uint8) (*AnalogInput, error) {
+	cfg := ADCConfig{posInput, negInput, gainId, nSamples}
+	if err := daq.checkADCConfig(cfg); err != nil {
+		return nil, err
+	}
+	diffMode := negInput != 0
+	return &AnalogInput{
+		daq:  daq,
+		cfg:  cfg,
+		cal1: daq.GetCalib(false, diffMode, false, posInput, gainId),
+		cal2: daq.GetCalib(false, diffMode, true, posInput, gainId),
+	}, nil
+}
+
+// Return the ADC configuration of the input
+func (in *AnalogInput) Config() ADCConfig {
+	return in.cfg
+}
+
+// Read a raw value from the input
+func (in *AnalogInput) ReadRaw() (int16, error) {
+	return in.daq.readChannel(in.cfg)
+}
+
+// Read a value in volts from the input
+func (in *AnalogInput) Read() (float32, error) {
+	val, err := in.ReadRaw()
+	if err != nil {
+		return 0, err
+	}
+	return in.daq.Adc.ToVolts(int(val), in.cfg.GainId, in.cal1, in.cal2), nil
+}
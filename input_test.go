@@ -0,0 +1,53 @@
+package godaq
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadChannel(t *testing.T) {
+	daq, sim := newSimDAQ(t)
+	sim.inputs = map[uint8]int16{1: -16384, 2: 8192}
+
+	v, err := daq.ReadChannel(1, 0, 1, 10)
+	assert.Nil(t, err)
+	assert.InDelta(t, 2.048, v, 1e-4)
+	v, err = daq.ReadChannel(1, 0, 1, 10)
+	assert.Nil(t, err)
+	assert.InDelta(t, 2.048, v, 1e-4)
+	assert.Len(t, sim.sent(AIN_CFG), 1)
+
+	_, err = daq.ReadChannel(1, 0, 99, 10)
+	assert.Equal(t, ErrInvalidGainID, err)
+}
+
+func TestReadChannelConcurrent(t *testing.T) {
+	daq, sim := newSimDAQ(t)
+	sim.inputs = map[uint8]int16{1: -16384, 2: 8192}
+	in1, err := daq.AnalogInput(1, 0, 1, 10)
+	assert.Nil(t, err)
+	in2, err := daq.AnalogInput(2, 0, 2, 10)
+	assert.Nil(t, err)
+	want1, _ := in1.Read()
+	want2, _ := in2.Read()
+	assert.NotEqual(t, want1, want2)
+
+	var wg sync.WaitGroup
+	read := func(in *AnalogInput, want float32) {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			v, err := in.Read()
+			assert.Nil(t, err)
+			assert.Equal(t, want, v)
+			v, err = daq.ReadChannel(in.Config().PosInput, 0, in.Config().GainId, 10)
+			assert.Nil(t, err)
+			assert.Equal(t, want, v)
+		}
+	}
+	wg.Add(2)
+	go read(in1, want1)
+	go read(in2, want2)
+	wg.Wait()
+}
@@ -0,0 +1,220 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Allowed states of a PIO
+type PIOLimit uint8
+
+const (
+	PIOAny PIOLimit = iota
+	PIOLowOnly
+	PIOHighOnly
+)
+
+// Error returned when a setpoint is outside the safe limits of an output.
+// For a PIO, Value, Min and Max are 0 (low) or 1 (high).
+type LimitError struct {
+	Output   string // "analog output" or "PIO"
+	N        uint
+	Value    float32
+	Min, Max float32
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s %d: value %g out of limits [%g, %g]", e.Output, e.N, e.Value, e.Min, e.Max)
+}
+
+// Kind of output change checked by the interlocks
+type ChangeKind uint8
+
+const (
+	ChangeAnalog ChangeKind = iota
+	ChangePIO
+	ChangePort
+)
+
+// Output change about to be written to the device
+type Change struct {
+	Kind  ChangeKind
+	N     uint    // Output number (ChangeAnalog) or PIO number (ChangePIO)
+	Volts float32 // ChangeAnalog
+	Value bool    // ChangePIO
+	Port  uint8   // ChangePort
+}
+
+// An interlock rule is checked before each SetAnalog, SetDAC, SetPIO and
+// SetPort. Returning an error blocks the change.
+// Rules run on the I/O goroutine with the device held, in the same operation
+// as the change. They must only use the daq argument, whose commands are sent
+// directly: calling a method of the OpenDAQ that checks the rule (for
+// instance one captured by the closure) waits for the I/O goroutine and
+// deadlocks.
+type Interlock func(daq *OpenDAQ, c Change) error
+
+// Error returned when a change is blocked by an interlock
+type InterlockError struct {
+	Name   string
+	Change Change
+	Err    error
+}
+
+func (e *InterlockError) Error() string {
+	return fmt.Sprintf("Interlock %q: %v", e.Name, e.Err)
+}
+
+func (e *InterlockError) Unwrap() error {
+	return e.Err
+}
+
+type namedInterlock struct {
+	name string
+	rule Interlock
+}
+
+// Set the safe limits (in volts) of analog output n.
+// Setpoints outside the limits return a *LimitError instead of being clamped.
+func (daq *OpenDAQ) SetOutputLimits(n uint, min, max float32) error {
+	if math.IsNaN(float64(min)) || math.IsNaN(float64(max)) ||
+		min > max || min < daq.Dac.VMin || max > daq.Dac.VMax {
+		return errors.New("Invalid output limits")
+	}
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	out := daq.output(n)
+	if out == nil {
+		return ErrInvalidOutput
+	}
+	out.limited, out.min, out.max = true, min, max
+	return nil
+}
+
+// Restrict the values that can be written to PIO n
+func (daq *OpenDAQ) SetPIOLimit(n uint, lim PIOLimit) error {
+	if n < 1 || n > uint(len(daq.pioLimits)) {
+		return ErrInvalidPIO
+	}
+	if lim > PIOHighOnly {
+		return errors.New("Invalid PIO limit")
+	}
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	daq.pioLimits[n-1] = lim
+	return nil
+}
+
+// Add an interlock rule. The rules are checked in the order they were added.
+// See Interlock for the restrictions of the rules.
+func (daq *OpenDAQ) AddInterlock(name string, rule Interlock) {
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	daq.interlocks = append(daq.interlocks, namedInterlock{name, rule})
+}
+
+// Remove all the interlock rules
+func (daq *OpenDAQ) ClearInterlocks() {
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	daq.interlocks = nil
+}
+
+// Interlock rule: analog output n must be at 0 V unless PIO pio reads high
+func ZeroUnlessPIOHigh(n, pio uint) Interlock {
+	return func(daq *OpenDAQ, c Change) error {
+		if c.Kind != ChangeAnalog || c.N != n || c.Volts == 0 {
+			return nil
+		}
+		val, err := daq.ReadPIO(pio)
+		if err != nil {
+			return err
+		}
+		if val == 0 {
+			return fmt.Errorf("output %d must be 0 V unless PIO %d is high", n, pio)
+		}
+		return nil
+	}
+}
+
+// Check a voltage against the limits of analog output n
+func (daq *OpenDAQ) checkAnalog(n uint, v float32) error {
+	return daq.checkAnalogLimits(n, v, true)
+}
+
+// Check a voltage against the limits set with SetOutputLimits only. Used for
+// raw DAC codes: any code is in range, but the calibrated voltage may not
+// fit in the nominal DAC range.
+func (daq *OpenDAQ) checkUserLimits(n uint, v float32) error {
+	return daq.checkAnalogLimits(n, v, false)
+}
+
+func (daq *OpenDAQ) checkAnalogLimits(n uint, v float32, dacRange bool) error {
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	out := daq.output(n)
+	if out == nil {
+		return ErrInvalidOutput
+	}
+	if !out.limited && !dacRange {
+		return nil
+	}
+	min, max := daq.Dac.VMin, daq.Dac.VMax
+	if out.limited {
+		min, max = out.min, out.max
+	}
+	if v < min || v > max || math.IsNaN(float64(v)) {
+		return &LimitError{"analog output", n, v, min, max}
+	}
+	return nil
+}
+
+// Check a value against the limit of PIO n
+func (daq *OpenDAQ) checkPIO(n uint, value bool) error {
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	if n < 1 || n > uint(len(daq.pioLimits)) {
+		return nil
+	}
+	var allowed float32
+	switch daq.pioLimits[n-1] {
+	case PIOLowOnly:
+		allowed = 0
+	case PIOHighOnly:
+		allowed = 1
+	default:
+		return nil
+	}
+	if v := float32(boolToByte(value)); v != allowed {
+		return &LimitError{"PIO", n, v, allowed, allowed}
+	}
+	return nil
+}
+
+// Run all the interlock rules for a change
+func (daq *OpenDAQ) checkInterlocks(c Change) error {
+	daq.stateLock.Lock()
+	rules := daq.interlocks
+	daq.stateLock.Unlock()
+
+	for _, il := range rules {
+		if err := il.rule(daq, c); err != nil {
+			return &InterlockError{il.name, c, err}
+		}
+	}
+	return nil
+}
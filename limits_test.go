@@ -0,0 +1,108 @@
+package godaq
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Device without port. Its queue is stopped when the test ends.
+func newTestDAQ(t *testing.T) *OpenDAQ {
+	cfg, _ := newConfig(nil)
+	daq := &OpenDAQ{conn: &conn{HwFeatures: NewModelM().GetFeatures(), cfg: cfg, queue: newQueue()}}
+	daq.initState()
+	t.Cleanup(daq.queue.stop)
+	return daq
+}
+
+func TestOutputLimits(t *testing.T) {
+	daq := newTestDAQ(t)
+	assert.Nil(t, daq.checkAnalog(1, 4.096))
+	assert.Equal(t, &LimitError{"analog output", 1, 5, -4.096, 4.096}, daq.checkAnalog(1, 5))
+	assert.Equal(t, ErrInvalidOutput, daq.checkAnalog(2, 0))
+
+	assert.NotNil(t, daq.SetOutputLimits(1, 2, 1))
+	nan := float32(math.NaN())
+	assert.NotNil(t, daq.SetOutputLimits(1, nan, 1))
+	assert.NotNil(t, daq.SetOutputLimits(1, 0, nan))
+	assert.NotNil(t, daq.SetOutputLimits(1, 0, 10))
+	assert.Nil(t, daq.SetOutputLimits(1, 0, 2.5))
+	assert.Nil(t, daq.checkAnalog(1, 2.5))
+	err := daq.SetAnalog(1, -0.5)
+	assert.IsType(t, &LimitError{}, err)
+	assert.EqualError(t, err, "analog output 1: value -0.5 out of limits [0, 2.5]")
+}
+
+func TestSetDACLimits(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	daq.calib[0].Gain = 1.1
+	// The calibrated full scale exceeds the nominal range
+	assert.True(t, daq.dacToVolts(-32768, 1) < daq.Dac.VMin)
+	assert.Nil(t, daq.SetDAC(1, -32768))
+	assert.Len(t, sim.sent(SET_DAC), 1)
+
+	assert.Nil(t, daq.SetOutputLimits(1, 0, 2.5))
+	assert.IsType(t, &LimitError{}, daq.SetDAC(1, -32768))
+	assert.Len(t, sim.sent(SET_DAC), 1)
+}
+
+func TestPIOLimits(t *testing.T) {
+	daq := newTestDAQ(t)
+	assert.Equal(t, ErrInvalidPIO, daq.SetPIOLimit(7, PIOLowOnly))
+	assert.Nil(t, daq.SetPIOLimit(3, PIOLowOnly))
+	assert.Nil(t, daq.checkPIO(3, false))
+	assert.Equal(t, &LimitError{"PIO", 3, 1, 0, 0}, daq.SetPIO(3, true))
+	assert.Equal(t, &LimitError{"PIO", 3, 1, 0, 0}, daq.SetPort(0x04))
+}
+
+func TestInterlocks(t *testing.T) {
+	daq := newTestDAQ(t)
+	errBlocked := errors.New("blocked")
+	var changes []Change
+	daq.AddInterlock("no PIO 2", func(daq *OpenDAQ, c Change) error {
+		changes = append(changes, c)
+		if c.Kind == ChangePIO && c.N == 2 {
+			return errBlocked
+		}
+		return nil
+	})
+
+	err := daq.SetPIO(2, true)
+	assert.Equal(t, &InterlockError{"no PIO 2", Change{Kind: ChangePIO, N: 2, Value: true}, errBlocked}, err)
+	assert.True(t, errors.Is(err, errBlocked))
+	assert.Len(t, changes, 1)
+
+	daq.ClearInterlocks()
+	assert.Nil(t, daq.checkInterlocks(Change{Kind: ChangePIO, N: 2}))
+}
+
+func TestZeroUnlessPIOHigh(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	daq.AddInterlock("enable", ZeroUnlessPIOHigh(1, 3))
+
+	err := daq.SetAnalog(1, 1)
+	assert.IsType(t, &InterlockError{}, err)
+	assert.EqualError(t, err, `Interlock "enable": output 1 must be 0 V unless PIO 3 is high`)
+	assert.Empty(t, sim.sent(SET_DAC))
+	// 0 V and other changes are always allowed
+	assert.Nil(t, daq.SetAnalog(1, 0))
+	assert.Nil(t, daq.SetPIO(1, true))
+
+	assert.Nil(t, daq.SetPIO(3, true))
+	assert.Nil(t, daq.SetAnalog(1, 1))
+	assert.Len(t, sim.sent(SET_DAC), 2)
+	// The PIO is read in the same operation as the write
+	assert.Equal(t, []Message{{PIO, []byte{3}}, {PIO, []byte{3}}}, filterPIOReads(sim.sent(PIO)))
+}
+
+func filterPIOReads(list []Message) []Message {
+	var reads []Message
+	for _, m := range list {
+		if len(m.Body) == 1 {
+			reads = append(reads, m)
+		}
+	}
+	return reads
+}
@@ -0,0 +1,44 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import "fmt"
+
+// Error returned when the port is in use by another process
+type BusyError struct {
+	Port string
+	PID  int // PID of the process holding the port (0 if unknown)
+}
+
+func (e *BusyError) Error() string {
+	if e.PID > 0 {
+		return fmt.Sprintf("Device %s busy (pid %d)", e.Port, e.PID)
+	}
+	return fmt.Sprintf("Device %s busy", e.Port)
+}
+
+// Open a serial port holding an exclusive lock on it
+func openLocked(port string, open func() (Transport, error)) (Transport, *portLock, error) {
+	lock, err := lockPort(port)
+	if err != nil {
+		return nil, nil, err
+	}
+	ser, err := open()
+	if err != nil {
+		lock.unlock()
+		return nil, nil, err
+	}
+	lock.exclusive()
+	return ser, lock, nil
+}
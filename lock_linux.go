@@ -0,0 +1,102 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Lock held on a serial port
+type portLock struct {
+	f *os.File
+}
+
+// Take an advisory exclusive lock (flock) on the port.
+// If the port is a terminal, exclusive mode (TIOCEXCL) is also enabled once
+// the port has been opened (see exclusive), so other processes can't open it
+// even if they don't use flock.
+func lockPort(port string) (*portLock, error) {
+	f, err := os.OpenFile(port, os.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		if pe, ok := err.(*os.PathError); ok && pe.Err == syscall.EBUSY {
+			return nil, &BusyError{port, lockOwner(port)}
+		}
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if err == syscall.EWOULDBLOCK {
+			return nil, &BusyError{port, lockOwner(port)}
+		}
+		return nil, err
+	}
+	return &portLock{f}, nil
+}
+
+// Enable the exclusive mode of the terminal
+func (l *portLock) exclusive() {
+	ioctl(l.f.Fd(), syscall.TIOCEXCL)
+}
+
+// Release the lock
+func (l *portLock) unlock() error {
+	ioctl(l.f.Fd(), syscall.TIOCNXCL)
+	return l.f.Close()
+}
+
+func ioctl(fd uintptr, req uintptr) error {
+	if _, _, e := syscall.Syscall(syscall.SYS_IOCTL, fd, req, 0); e != 0 {
+		return e
+	}
+	return nil
+}
+
+// Return the PID of the process holding a flock on a file, or 0 if unknown
+func lockOwner(path string) int {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0
+	}
+	dev := uint64(st.Dev)
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+	id := fmt.Sprintf("%02x:%02x:%d", major, minor, st.Ino)
+
+	f, err := os.Open("/proc/locks")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	// Format: "1: FLOCK  ADVISORY  WRITE 1234 08:01:5678 0 EOF"
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 6 || fields[1] != "FLOCK" || fields[5] != id {
+			continue
+		}
+		if pid, err := strconv.Atoi(fields[4]); err == nil {
+			return pid
+		}
+	}
+	return 0
+}
@@ -0,0 +1,28 @@
+package godaq
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockPort(t *testing.T) {
+	f, err := ioutil.TempFile("", "ttyUSB")
+	assert.Nil(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	lock, err := lockPort(f.Name())
+	assert.Nil(t, err)
+	lock.exclusive()
+
+	_, err = lockPort(f.Name())
+	assert.Equal(t, &BusyError{f.Name(), os.Getpid()}, err)
+
+	assert.Nil(t, lock.unlock())
+	lock, err = lockPort(f.Name())
+	assert.Nil(t, err)
+	lock.unlock()
+}
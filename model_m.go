@@ -33,6 +33,9 @@ func NewModelM() *ModelM {
 		NOutputs:   nOutputs,
 		NCalibRegs: nOutputs + nInputs + uint(len(adcGainsM)),
 
+		// Size of the signal buffer used for waveform generation
+		NSignalPoints: 400,
+
 		Adc: ADC{Bits: 16, Signed: true, VMin: -4.096, VMax: 4.096,
 			Invert: true, Gains: adcGainsM},
 		Dac: DAC{Bits: 16, Signed: true, VMin: -4.096, VMax: 4.096},
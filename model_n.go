@@ -33,6 +33,9 @@ func NewModelN() *ModelN {
 		NOutputs:   nOutputs,
 		NCalibRegs: nOutputs + 2*(nInputs+uint(len(adcGainsN))),
 
+		// Size of the signal buffer used for waveform generation
+		NSignalPoints: 400,
+
 		Adc: ADC{Bits: 16, Signed: true, VMin: -12.288, VMax: 12.288, Gains: adcGainsN},
 		// The DAC has 12 bits, but the firmware transforms the values
 		Dac: DAC{Bits: 16, Signed: true, VMin: -4.096, VMax: 4.096},
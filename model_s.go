@@ -33,6 +33,9 @@ func NewModelS() *ModelS {
 		NOutputs:   nOutputs,
 		NCalibRegs: nOutputs + 2*nInputs,
 
+		// Size of the signal buffer used for waveform generation
+		NSignalPoints: 400,
+
 		Adc: ADC{Bits: 16, Signed: true, VMin: -12.0, VMax: 12.0, Gains: adcGainsS},
 		// The DAC has 12 bits, but the firmware transforms the values
 		Dac: DAC{Bits: 16, Signed: true, VMin: 0.0, VMax: 4.096},
@@ -14,10 +14,11 @@
 package godaq
 
 import (
-	"encoding/binary"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"sync"
 	"time"
 
@@ -35,19 +36,34 @@ const (
 )
 
 const (
-	AIN         = 1
-	AIN_CFG     = 2
-	PIO         = 3
-	AIN_ALL     = 4
-	PIO_DIR     = 5
-	PORT        = 7
-	PORT_DIR    = 9
-	SET_DAC     = 13
-	LED_W       = 18
-	SET_ANALOG  = 24
-	GET_CALIB   = 36
-	ID_CONFIG   = 39
-	GET_AIN_CFG = 40
+	AIN            = 1
+	AIN_CFG        = 2
+	PIO            = 3
+	AIN_ALL        = 4
+	PIO_DIR        = 5
+	PORT           = 7
+	PORT_DIR       = 9
+	SET_DAC        = 13
+	CAPTURE_INIT   = 14
+	CAPTURE_STOP   = 15
+	CAPTURE_GET    = 16
+	LED_W          = 18
+	BURST_CREATE   = 21
+	CHANNEL_CFG    = 22
+	SIGNAL_LOAD    = 23
+	SET_ANALOG     = 24
+	SPISW_CONFIG   = 26
+	SPISW_SETUP    = 28
+	SPISW_TRANSFER = 29
+	CHANNEL_SETUP  = 32
+	GET_CALIB      = 36
+	ID_CONFIG      = 39
+	GET_AIN_CFG    = 40
+	ENCODER_INIT   = 50
+	ENCODER_STOP   = 51
+	ENCODER_GET    = 52
+	STREAM_START   = 64
+	STREAM_STOP    = 80
 )
 
 var (
@@ -59,6 +75,7 @@ var (
 	ErrInvalidGainID   = errors.New("Invalid gain ID")
 	ErrInvalidID       = errors.New("ID out of range")
 	ErrInvalidPIOValue = errors.New("Invalid PIO value")
+	ErrNotReady        = errors.New("Device not ready")
 )
 
 type Calib struct {
@@ -71,6 +88,7 @@ type HwFeatures struct {
 	NPIOs, NLeds                      uint
 	NInputs, NOutputs, NHiddenOutputs uint
 	NCalibRegs                        uint
+	NSignalPoints                     uint
 	Dac                               DAC
 	Adc                               ADC
 }
@@ -98,70 +116,217 @@ func boolToByte(val bool) byte {
 	return 0
 }
 
+// Connection to a device
 type OpenDAQ struct {
-	ser *serial.Port
+	*conn
+	held bool // The device is held by a transaction (see Do)
+}
+
+// State shared by an OpenDAQ and its transactions
+type conn struct {
+	ser  Transport
+	lock *portLock
 	HwFeatures
 	hw    HwModel
-	calib []Calib
-	sync.Mutex
-
-	// Input state (needed for converting ADC values to volts)
-	gainId   uint
-	posInput uint
-	diffMode bool
+	cfg   *config
+	queue *ioQueue // Owns the port once the device is open
+
+	calibLock sync.Mutex
+	calib     []Calib
+
+	// Shadow state of the device, output limits and interlocks.
+	// The ADC configuration is also needed for converting ADC values to volts.
+	stateLock  sync.Mutex
+	adc        ADCConfig
+	adcKnown   bool
+	outputs    []outputState
+	pios       []PIOState
+	leds       []LEDState
+	pioLimits  []PIOLimit
+	interlocks []namedInterlock
+
+	// Safe state and host-side watchdog
+	safeLock   sync.Mutex
+	safe       *SafeState
+	wdTimer    *time.Timer
+	wdTimeout  time.Duration
+	wdOnTrip   func(error)
+	wdApplying bool
+
+	// Identity of the device and automatic reconnection (the config is
+	// guarded by safeLock, the rest is owned by the I/O goroutine)
+	info         DevicePort
+	reconnect    *ReconnectConfig
+	reconnecting bool // The port is closed while the device is searched
+	opts         []Option
 }
 
-func New(port string) (*OpenDAQ, error) {
-	var err error
-	daq := OpenDAQ{}
-	daq.posInput = 1 // 0 is not a valid default for posInput
-
-	// Setup and open the serial port
-	serCfg := &serial.Config{Name: port, Baud: 115200, ReadTimeout: time.Millisecond * 100}
-	daq.ser, err = serial.OpenPort(serCfg)
+// Open the device connected to a serial port.
+// By default, New waits until the device answers (see WithReadyTimeout) and
+// reads the calibration from the device (see WithCalibFile).
+func New(port string, opts ...Option) (*OpenDAQ, error) {
+	cfg, err := newConfig(opts)
 	if err != nil {
 		return nil, err
 	}
-	time.Sleep(1500 * time.Millisecond)
+	daq := &OpenDAQ{conn: &conn{cfg: cfg, opts: opts}}
+	daq.adc.PosInput = 1 // 0 is not a valid default for PosInput
 
-	// Obtain the device model number
-	model, _, _, err := daq.GetInfo()
-	if err != nil {
+	// Setup and open the serial port
+	daq.ser = cfg.transport
+	if daq.ser == nil {
+		open := func() (Transport, error) {
+			serCfg := &serial.Config{Name: port, Baud: cfg.baud, ReadTimeout: cfg.readTimeout}
+			ser, err := serial.OpenPort(serCfg)
+			if err != nil {
+				return nil, err
+			}
+			return ser, nil
+		}
+		if cfg.noLock {
+			daq.ser, err = open()
+		} else {
+			daq.ser, daq.lock, err = openLocked(port, open)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.recording != nil {
+		daq.ser = NewRecorder(daq.ser, cfg.recording)
+	}
+	daq.queue = newQueue()
+	if err = daq.init(port); err != nil {
+		daq.queue.stop()
+		daq.closePort()
 		return nil, err
 	}
-	hw, ok := hwModels[model]
+	registerPort(daq.info)
+	return daq, nil
+}
+
+// Identify the device and load its calibration
+func (daq *OpenDAQ) init(port string) (err error) {
+	cfg := daq.cfg
+	time.Sleep(cfg.bootDelay)
+
+	// Wait for the bootloader and obtain the device model number
+	if cfg.model == 0 || cfg.readyTimeout > 0 {
+		if daq.info, err = handshake(daq.ser, cfg.readyTimeout, cfg.tracer); err != nil {
+			return err
+		}
+	}
+	if cfg.model != 0 {
+		daq.info.Model = cfg.model
+	}
+	daq.info.Port = port
+	hw, ok := hwModels[daq.info.Model]
 	if !ok {
-		return nil, ErrUnknownModel
+		return ErrUnknownModel
 	}
 	daq.hw = hw
 	daq.HwFeatures = hw.GetFeatures()
+	daq.initState()
 
-	// Read the calibration registers from the device
-	daq.calib = make([]Calib, daq.NCalibRegs)
-	for i := range daq.calib {
-		if daq.calib[i], err = daq.readCalib(uint8(i)); err != nil {
-			return nil, err
+	if cfg.calibSource == calibLazy {
+		return nil
+	}
+	daq.calib, err = daq.loadCalib()
+	return err
+}
+
+// Ask for the device identity (ID_CONFIG) until the device answers or the
+// timeout expires. Each attempt waits for the read timeout of the port.
+// Read timeouts, NAKs and invalid responses are retried alike, since a
+// booting device can answer with garbage.
+func handshake(ser Transport, timeout time.Duration, t Tracer) (dp DevicePort, err error) {
+	deadline := time.Now().Add(timeout)
+	c := commands[ID_CONFIG]
+	for attempt := 1; ; attempt++ {
+		var buf io.Reader
+		if buf, err = traceCommand(ser, &Message{Number: ID_CONFIG}, c.RespLen, t, attempt); err == nil {
+			var vals Values
+			b, _ := ioutil.ReadAll(buf)
+			if vals, err = c.Response.Decode(b); err == nil {
+				dp.Model, dp.Version, dp.Serial = parseInfo(vals)
+				return dp, nil
+			}
+		}
+		if time.Now().After(deadline) {
+			return dp, fmt.Errorf("%w: %v", ErrNotReady, err)
 		}
+		ser.Flush()
 	}
-	return &daq, nil
 }
 
+// Apply the safe state (if any) and close the device
 func (daq *OpenDAQ) Close() error {
-	return daq.ser.Close()
+	daq.SetWatchdog(0, nil)
+	daq.DisableAutoReconnect()
+	err := daq.ApplySafeState()
+	e := daq.exec(PrioritySafety, func(d *OpenDAQ) error {
+		if d.reconnecting {
+			// The port is already closed
+			return nil
+		}
+		unregisterPort(d.info.Port)
+		return d.closePort()
+	})
+	daq.queue.stop()
+	if err == nil {
+		err = e
+	}
+	return err
 }
 
-// Send a comand and returns its response
+// Close the serial port and release its lock
+func (daq *OpenDAQ) closePort() error {
+	err := daq.ser.Close()
+	if daq.lock != nil {
+		daq.lock.unlock()
+		daq.lock = nil
+	}
+	return err
+}
+
+// Send a comand through the command queue and returns its response
 func (daq *OpenDAQ) sendCommand(command *Message, respLen int) (r io.Reader, err error) {
-	daq.Lock()
-	defer daq.Unlock()
-	// Retry the command up to 8 times
+	e := daq.exec(commandPriority(command), func(d *OpenDAQ) error {
+		r, err = d.command(command, respLen)
+		return nil
+	})
+	if e != nil {
+		return nil, e
+	}
+	return
+}
+
+// Send a command with the device already held. If the port has been lost,
+// the reconnection is started and ErrDisconnected is returned.
+func (daq *OpenDAQ) command(command *Message, respLen int) (r io.Reader, err error) {
+	if daq.reconnecting {
+		return nil, ErrDisconnected
+	}
+	r, err = daq.tryCommand(command, respLen)
+	if err != nil && daq.shouldReconnect(err) {
+		daq.startReconnect()
+		return nil, ErrDisconnected
+	}
+	return
+}
+
+// Send the command retrying up to the configured number of attempts
+func (daq *OpenDAQ) tryCommand(command *Message, respLen int) (r io.Reader, err error) {
 	err = try.Do(func(attempt int) (bool, error) {
 		var e error
-		r, e = sendCommand(daq.ser, command, respLen)
+		r, e = traceCommand(daq.ser, command, respLen, daq.cfg.tracer, attempt)
 		if e != nil {
 			daq.ser.Flush()
+			if attempt < daq.cfg.retries {
+				time.Sleep(daq.cfg.retryDelay)
+			}
 		}
-		return attempt < 8, e
+		return attempt < daq.cfg.retries, e
 	})
 	return
 }
@@ -174,7 +339,11 @@ func (daq *OpenDAQ) GetCalib(isOutput, diffMode, secondStage bool, n, gainId uin
 	if err != nil {
 		return Calib{1, 0}
 	}
-	return daq.calib[idx]
+	calib := daq.calibration()
+	if idx >= uint(len(calib)) {
+		return Calib{1, 0}
+	}
+	return calib[idx]
 }
 
 // Convert a voltage to a DAC value given the number of the output
@@ -184,48 +353,47 @@ func (daq *OpenDAQ) voltsToDac(v float32, n uint) int {
 	return daq.Dac.FromVolts(v, cal)
 }
 
-// Convert an ADC value to volts
-func (daq *OpenDAQ) adcToVolts(raw int) float32 {
+// Convert a DAC value to volts given the number of the output
+func (daq *OpenDAQ) dacToVolts(val int, n uint) float32 {
+	cal := daq.GetCalib(true, false, false, n, 0)
+	return daq.Dac.ToVolts(val, cal)
+}
+
+// Convert an ADC value read with the given configuration to volts
+func (daq *OpenDAQ) adcToVolts(raw int, cfg ADCConfig) float32 {
 	// TODO: add caching?
-	cal1 := daq.GetCalib(false, daq.diffMode, false, daq.posInput, daq.gainId)
-	cal2 := daq.GetCalib(false, daq.diffMode, true, daq.posInput, daq.gainId)
-	return daq.Adc.ToVolts(raw, daq.gainId, cal1, cal2)
+	diffMode := cfg.NegInput != 0
+	cal1 := daq.GetCalib(false, diffMode, false, cfg.PosInput, cfg.GainId)
+	cal2 := daq.GetCalib(false, diffMode, true, cfg.PosInput, cfg.GainId)
+	return daq.Adc.ToVolts(raw, cfg.GainId, cal1, cal2)
 }
 
 func (daq *OpenDAQ) GetInfo() (model, version uint8, serial string, err error) {
-	var buf io.Reader
-	buf, err = daq.sendCommand(&Message{Number: ID_CONFIG}, 6)
+	vals, err := daq.call(ID_CONFIG)
 	if err != nil {
 		return
 	}
-	var info = struct {
-		Model, Version uint8
-		Serial         uint32
-	}{}
-	binary.Read(buf, binary.BigEndian, &info)
-	model = info.Model
-	version = info.Version
-	serial = fmt.Sprintf("%04d", info.Serial)
+	model, version, serial = parseInfo(vals)
 	return
 }
 
+// Decode the response of an ID_CONFIG command
+func parseInfo(vals Values) (model, version uint8, serial string) {
+	return uint8(vals.mustInt("model")), uint8(vals.mustInt("version")), fmt.Sprintf("%04d", vals.mustInt("serial"))
+}
+
 // Read the calibration register stored at index nReg
 func (daq *OpenDAQ) readCalib(nReg uint8) (Calib, error) {
-	buf, err := daq.sendCommand(&Message{GET_CALIB, []byte{nReg}}, 5)
+	vals, err := daq.call(GET_CALIB, nReg)
 	if err != nil {
 		return Calib{1, 0}, err
 	}
-	var ret = struct {
-		_    uint8
-		Gain int16
-		Offs int16
-	}{}
-	binary.Read(buf, binary.BigEndian, &ret)
+	gain, offs := float32(vals.mustInt("gain")), float32(vals.mustInt("offset"))
 	//TODO: refactor this
 	if uint(nReg) < daq.NOutputs+daq.NHiddenOutputs {
-		return Calib{1. + float32(ret.Gain)/(1<<16), float32(ret.Offs) / (1 << 16)}, nil
+		return Calib{1. + gain/(1<<16), offs / (1 << 16)}, nil
 	}
-	return Calib{1. + float32(ret.Gain)/(1<<16), float32(ret.Offs) / (1 << 5)}, nil
+	return Calib{1. + gain/(1<<16), offs / (1 << 5)}, nil
 }
 
 func (daq *OpenDAQ) SetLED(n uint, c Color) error {
@@ -235,70 +403,166 @@ func (daq *OpenDAQ) SetLED(n uint, c Color) error {
 	if c > 3 {
 		return errors.New("Invalid LED color")
 	}
-	_, err := daq.sendCommand(&Message{LED_W, []byte{byte(c), byte(n)}}, 2)
+	_, err := daq.call(LED_W, c, n)
+	if err == nil {
+		daq.storeLED(n, c)
+	}
 	return err
 }
 
 func (daq *OpenDAQ) ConfigureADC(posInput, negInput, gainId uint, nSamples uint8) error {
-	if err := daq.hw.CheckValidInputs(posInput, negInput); err != nil {
+	cfg := ADCConfig{posInput, negInput, gainId, nSamples}
+	if err := daq.checkADCConfig(cfg); err != nil {
+		return err
+	}
+	return daq.exec(PriorityRead, func(d *OpenDAQ) error {
+		return d.configureADC(cfg)
+	})
+}
+
+// Check that an ADC configuration is valid for the device
+func (daq *OpenDAQ) checkADCConfig(cfg ADCConfig) error {
+	if err := daq.hw.CheckValidInputs(cfg.PosInput, cfg.NegInput); err != nil {
 		return err
 	}
-	if gainId >= uint(len(daq.Adc.Gains)) {
+	if cfg.GainId >= uint(len(daq.Adc.Gains)) {
 		return ErrInvalidGainID
 	}
-	daq.posInput = posInput
-	daq.gainId = gainId
-	daq.diffMode = false
-	if negInput != 0 {
-		daq.diffMode = true
+	return nil
+}
+
+// Configure the ADC with the device already held
+func (daq *OpenDAQ) configureADC(cfg ADCConfig) error {
+	daq.stateLock.Lock()
+	daq.adc = cfg
+	daq.adcKnown = false
+	daq.stateLock.Unlock()
+	_, err := daq.call(AIN_CFG, cfg.PosInput, cfg.NegInput, cfg.GainId, cfg.NSamples)
+	if err == nil {
+		daq.stateLock.Lock()
+		daq.adcKnown = true
+		daq.stateLock.Unlock()
 	}
-	_, err := daq.sendCommand(&Message{AIN_CFG, []byte{byte(posInput), byte(negInput),
-		byte(gainId), nSamples}}, 6)
 	return err
 }
 
 // Read a raw value from the ADC
-func (daq *OpenDAQ) ReadADC() (int16, error) {
-	buf, err := daq.sendCommand(&Message{Number: AIN}, 2)
-	if err != nil {
-		return 0, err
-	}
-	var val int16
-	binary.Read(buf, binary.BigEndian, &val)
-	return val, nil
+func (daq *OpenDAQ) ReadADC() (val int16, err error) {
+	err = daq.exec(PriorityRead, func(d *OpenDAQ) (e error) {
+		val, _, e = d.readADC()
+		return
+	})
+	return
 }
 
 // Read a value in volts from the ADC
 func (daq *OpenDAQ) ReadAnalog() (float32, error) {
-	val, err := daq.ReadADC()
+	var val int16
+	var cfg ADCConfig
+	err := daq.exec(PriorityRead, func(d *OpenDAQ) (e error) {
+		val, cfg, e = d.readADC()
+		return
+	})
 	if err != nil {
 		return 0, err
 	}
-	return daq.adcToVolts(int(val)), nil
+	return daq.adcToVolts(int(val), cfg), nil
+}
+
+// Read a raw value from the ADC with the device already held. The ADC
+// configuration the value was read with is also returned.
+func (daq *OpenDAQ) readADC() (val int16, cfg ADCConfig, err error) {
+	vals, err := daq.call(AIN)
+	if err != nil {
+		return
+	}
+	val = int16(vals.mustInt("value"))
+	daq.stateLock.Lock()
+	cfg = daq.adc
+	daq.stateLock.Unlock()
+	return
 }
 
-// Set the raw value of the DAC at output n
+// Set the raw value of the DAC at output n. Any code is accepted unless the
+// output has been limited with SetOutputLimits.
 func (daq *OpenDAQ) SetDAC(n uint, val int) error {
+	return daq.exec(PriorityOutput, func(d *OpenDAQ) error {
+		volts := d.dacToVolts(val, n)
+		if err := d.checkUserLimits(n, volts); err != nil {
+			return err
+		}
+		if err := d.checkInterlocks(Change{Kind: ChangeAnalog, N: n, Volts: volts}); err != nil {
+			return err
+		}
+		if err := d.writeDAC(n, val); err != nil {
+			return err
+		}
+		d.storeOutput(n, val, volts)
+		return nil
+	})
+}
+
+func (daq *OpenDAQ) writeDAC(n uint, val int) error {
 	if n < 1 || n > (daq.NOutputs+daq.NHiddenOutputs) {
 		return ErrInvalidOutput
 	}
-	out := toBytes(int16(val))
-	out = append(out, byte(n))
-	_, err := daq.sendCommand(&Message{SET_DAC, out}, 3)
+	_, err := daq.call(SET_DAC, int16(val), n)
 	return err
 }
 
-// Set the voltage at output n
+// Set the voltage at output n.
+// If a slew limit has been set for the output, the voltage is ramped up or
+// down to the new value (see SetSlewLimit). Inside a transaction, such an
+// output returns ErrRampInTx.
+// A voltage outside the limits of the output returns a *LimitError.
 func (daq *OpenDAQ) SetAnalog(n uint, val float32) error {
-	return daq.SetDAC(n, daq.voltsToDac(val, n))
+	if err := daq.checkAnalog(n, val); err != nil {
+		return err
+	}
+	if rate, step := daq.slewLimit(n); rate > 0 {
+		return daq.RampAnalog(context.Background(), n, val, rate, step)
+	}
+	return daq.exec(PriorityOutput, func(d *OpenDAQ) error {
+		if err := d.checkInterlocks(Change{Kind: ChangeAnalog, N: n, Volts: val}); err != nil {
+			return err
+		}
+		return d.setAnalog(n, val)
+	})
+}
+
+// Set the voltage at output n without slew limiting nor interlocks
+func (daq *OpenDAQ) setAnalog(n uint, val float32) error {
+	if err := daq.checkAnalog(n, val); err != nil {
+		return err
+	}
+	raw := daq.voltsToDac(val, n)
+	if err := daq.writeDAC(n, raw); err != nil {
+		return err
+	}
+	daq.storeOutput(n, raw, val)
+	return nil
 }
 
 func (daq *OpenDAQ) SetPIO(n uint, value bool) error {
 	if n < 1 || n > daq.NPIOs {
 		return ErrInvalidPIO
 	}
-	val := boolToByte(value)
-	_, err := daq.sendCommand(&Message{PIO, []byte{byte(n), val}}, 2)
+	return daq.exec(PriorityOutput, func(d *OpenDAQ) error {
+		if err := d.checkPIO(n, value); err != nil {
+			return err
+		}
+		if err := d.checkInterlocks(Change{Kind: ChangePIO, N: n, Value: value}); err != nil {
+			return err
+		}
+		return d.writePIO(n, value)
+	})
+}
+
+func (daq *OpenDAQ) writePIO(n uint, value bool) error {
+	_, err := daq.call(PIO, n, value)
+	if err == nil {
+		daq.storePIO(n, value)
+	}
 	return err
 }
 
@@ -306,8 +570,10 @@ func (daq *OpenDAQ) SetPIODir(n uint, out bool) error {
 	if n < 1 || n > daq.NPIOs {
 		return ErrInvalidPIO
 	}
-	dir := boolToByte(out)
-	_, err := daq.sendCommand(&Message{PIO_DIR, []byte{byte(n), dir}}, 2)
+	_, err := daq.call(PIO_DIR, n, out)
+	if err == nil {
+		daq.storePIODir(n, out)
+	}
 	return err
 }
 
@@ -315,13 +581,11 @@ func (daq *OpenDAQ) ReadPIO(n uint) (uint8, error) {
 	if n < 1 || n > daq.NPIOs {
 		return 0, ErrInvalidPIO
 	}
-	buf, err := daq.sendCommand(&Message{PIO, []byte{byte(n)}}, 2)
-	var ret = struct {
-		N_PIO uint8
-		Read  uint8
-	}{}
-	binary.Read(buf, binary.BigEndian, &ret)
-	return ret.Read, err
+	vals, err := daq.call(PIO, n)
+	if err != nil {
+		return 0, err
+	}
+	return uint8(vals.mustInt("value")), nil
 }
 
 // Configure all PIO direction.
@@ -329,17 +593,21 @@ func (daq *OpenDAQ) SetPortDir(dir_port uint8) error {
 	if dir_port < 0 || dir_port >= (1<<daq.NPIOs) {
 		return ErrInvalidPIOValue
 	} else {
-		_, err := daq.sendCommand(&Message{PORT_DIR, []byte{byte(dir_port)}}, 1)
+		_, err := daq.call(PORT_DIR, dir_port)
+		if err == nil {
+			daq.storePortDir(dir_port)
+		}
 		return err
 	}
 }
 
 // ead all PIO values.
 func (daq *OpenDAQ) ReadPort() (uint8, error) {
-	var read_value uint8
-	buf, err := daq.sendCommand(&Message{Number: PORT}, 1)
-	binary.Read(buf, binary.BigEndian, &read_value)
-	return read_value, err
+	vals, err := daq.call(PORT)
+	if err != nil {
+		return 0, err
+	}
+	return uint8(vals.mustInt("value")), nil
 }
 
 // Write all PIO values.
@@ -347,8 +615,21 @@ func (daq *OpenDAQ) SetPort(value_port uint8) error {
 	if value_port < 0 || value_port >= (1<<daq.NPIOs) {
 		return ErrInvalidPIOValue
 	} else {
-		_, err := daq.sendCommand(&Message{PORT, []byte{byte(value_port)}}, 1)
-		return err
+		return daq.exec(PriorityOutput, func(d *OpenDAQ) error {
+			for i := uint(0); i < d.NPIOs; i++ {
+				if err := d.checkPIO(i+1, value_port&(1<<i) != 0); err != nil {
+					return err
+				}
+			}
+			if err := d.checkInterlocks(Change{Kind: ChangePort, Port: value_port}); err != nil {
+				return err
+			}
+			_, err := d.call(PORT, value_port)
+			if err == nil {
+				d.storePort(value_port)
+			}
+			return err
+		})
 	}
 }
 
@@ -356,13 +637,11 @@ func (daq *OpenDAQ) SetId(id uint32) (uint16, error) {
 	if id < 0 || id > 1000 {
 		return 0, ErrInvalidID
 	}
-	var ret = struct {
-		HW   uint8
-		FW   uint8
-		RESP uint16
-	}{}
-	out := toBytes(int32(id))
-	buf, err := daq.sendCommand(&Message{ID_CONFIG, out}, 6)
-	binary.Read(buf, binary.BigEndian, &ret)
-	return ret.RESP, err
+	vals, err := daq.call(ID_CONFIG, id)
+	if err != nil {
+		return 0, err
+	}
+	// The answer is the 16-bit value that follows the hardware and firmware
+	// versions
+	return uint16(vals.mustInt("serial") >> 16), nil
 }
@@ -0,0 +1,206 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	try "gopkg.in/matryer/try.v1"
+)
+
+type calibSource uint8
+
+const (
+	calibDevice calibSource = iota
+	calibFile
+	calibIdentity
+	calibLazy
+)
+
+// Option of the connection to a device (see New)
+type Option func(*config) error
+
+type config struct {
+	// Serial port
+	transport   Transport
+	baud        int
+	readTimeout time.Duration
+
+	// Retry policy
+	retries    int
+	retryDelay time.Duration
+
+	// Startup
+	readyTimeout time.Duration
+	bootDelay    time.Duration
+	model        uint8
+
+	calibSource calibSource
+	calibFile   string
+
+	noLock    bool
+	tracer    Tracer
+	recording io.Writer
+}
+
+func newConfig(opts []Option) (*config, error) {
+	cfg := &config{
+		baud:         115200,
+		readTimeout:  100 * time.Millisecond,
+		retries:      8,
+		readyTimeout: 3 * time.Second,
+	}
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+	return cfg, nil
+}
+
+// Set the baud rate of the serial port (default: 115200)
+func WithBaud(baud int) Option {
+	return func(cfg *config) error {
+		if baud <= 0 {
+			return errors.New("Invalid baud rate")
+		}
+		cfg.baud = baud
+		return nil
+	}
+}
+
+// Set the time to wait for each response (default: 100 ms).
+// The serial port has a resolution of 100 ms.
+func WithReadTimeout(d time.Duration) Option {
+	return func(cfg *config) error {
+		if d <= 0 {
+			return errors.New("Invalid read timeout")
+		}
+		cfg.readTimeout = d
+		return nil
+	}
+}
+
+// Don't lock the serial port. By default, New takes an exclusive lock on the
+// port and fails with a *BusyError if another process holds it.
+func WithoutLock() Option {
+	return func(cfg *config) error {
+		cfg.noLock = true
+		return nil
+	}
+}
+
+// Use an already open transport instead of opening the serial port.
+// The port name passed to New is only used to identify the device.
+func WithTransport(t Transport) Option {
+	return func(cfg *config) error {
+		cfg.transport = t
+		return nil
+	}
+}
+
+// Set the number of attempts for each command (default: 8) and the delay
+// between them (default: none)
+func WithRetries(attempts int, delay time.Duration) Option {
+	return func(cfg *config) error {
+		if attempts < 1 || attempts > try.MaxRetries {
+			return fmt.Errorf("The number of attempts must be between 1 and %d", try.MaxRetries)
+		}
+		cfg.retries = attempts
+		cfg.retryDelay = delay
+		return nil
+	}
+}
+
+// Set the maximum time to wait for the device to answer after opening the
+// port (default: 3 s). The device is polled, so New returns as soon as the
+// bootloader has finished. With WithModel, a zero timeout skips the
+// handshake.
+func WithReadyTimeout(d time.Duration) Option {
+	return func(cfg *config) error {
+		cfg.readyTimeout = d
+		return nil
+	}
+}
+
+// Wait a fixed time after opening the port, before polling the device.
+// Use WithBootDelay(1500 * time.Millisecond) for boards that don't handle
+// commands sent while booting.
+func WithBootDelay(d time.Duration) Option {
+	return func(cfg *config) error {
+		cfg.bootDelay = d
+		return nil
+	}
+}
+
+// Use the given model (ModelMId, ModelSId...) instead of the one reported by
+// the device
+func WithModel(model uint8) Option {
+	return func(cfg *config) error {
+		if _, ok := hwModels[model]; !ok {
+			return ErrUnknownModel
+		}
+		cfg.model = model
+		return nil
+	}
+}
+
+// Load the calibration from a file written by WriteCalibFile instead of
+// reading it from the device. The file must match the model and the serial
+// number of the device; only the model is checked if the device isn't
+// identified (see WithModel).
+func WithCalibFile(path string) Option {
+	return func(cfg *config) error {
+		cfg.calibSource = calibFile
+		cfg.calibFile = path
+		return nil
+	}
+}
+
+// Use identity calibration values (gain 1, offset 0)
+func WithIdentityCalib() Option {
+	return func(cfg *config) error {
+		cfg.calibSource = calibIdentity
+		return nil
+	}
+}
+
+// Read the calibration from the device when it's first needed instead of
+// in New
+func WithLazyCalib() Option {
+	return func(cfg *config) error {
+		cfg.calibSource = calibLazy
+		return nil
+	}
+}
+
+// Report every request and response frame to a tracer (see NewHexTracer)
+func WithTracer(t Tracer) Option {
+	return func(cfg *config) error {
+		cfg.tracer = t
+		return nil
+	}
+}
+
+// Record the exchanged frames to w (see NewRecorder). The recording can be
+// replayed with WithTransport and a Replay.
+func WithRecording(w io.Writer) Option {
+	return func(cfg *config) error {
+		cfg.recording = w
+		return nil
+	}
+}
@@ -0,0 +1,52 @@
+package godaq
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions(t *testing.T) {
+	cfg, err := newConfig(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 115200, cfg.baud)
+	assert.Equal(t, 3*time.Second, cfg.readyTimeout)
+	assert.Equal(t, time.Duration(0), cfg.bootDelay)
+	assert.Equal(t, 8, cfg.retries)
+
+	cfg, err = newConfig([]Option{WithReadyTimeout(time.Second), WithBootDelay(1500 * time.Millisecond),
+		WithBaud(9600), WithRetries(3, time.Millisecond), WithModel(ModelSId), WithLazyCalib()})
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second, cfg.readyTimeout)
+	assert.Equal(t, 1500*time.Millisecond, cfg.bootDelay)
+	assert.Equal(t, 9600, cfg.baud)
+	assert.Equal(t, 3, cfg.retries)
+	assert.EqualValues(t, ModelSId, cfg.model)
+	assert.Equal(t, calibLazy, cfg.calibSource)
+
+	_, err = newConfig([]Option{WithRetries(0, 0)})
+	assert.NotNil(t, err)
+	_, err = newConfig([]Option{WithModel(99)})
+	assert.Equal(t, ErrUnknownModel, err)
+}
+
+func TestHandshakeRetry(t *testing.T) {
+	sim := &simDevice{model: ModelMId, serial: 42, fail: 3}
+	daq, err := New("sim", WithTransport(sim), WithReadyTimeout(time.Second), WithIdentityCalib())
+	assert.Nil(t, err)
+	defer daq.Close()
+	assert.Equal(t, DevicePort{Port: "sim", Model: ModelMId, Version: 1, Serial: "0042"}, daq.info)
+	assert.Len(t, sim.sent(ID_CONFIG), 4)
+}
+
+func TestHandshakeNotReady(t *testing.T) {
+	sim := &simDevice{model: ModelMId, serial: 42, fail: math.MaxInt32}
+	start := time.Now()
+	_, err := New("sim", WithTransport(sim), WithReadyTimeout(50*time.Millisecond))
+	assert.True(t, errors.Is(err, ErrNotReady))
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.True(t, len(sim.sent(ID_CONFIG)) > 1)
+}
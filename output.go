@@ -0,0 +1,183 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnknownOutput = errors.New("Unknown output voltage: set it once without slew limit")
+	ErrInvalidSlew   = errors.New("Invalid slew rate or step")
+	ErrRampInTx      = errors.New("Ramps can't run inside a transaction")
+)
+
+type outputState struct {
+	AnalogState
+
+	// Safe limits in volts. The DAC range is used if not limited.
+	limited  bool
+	min, max float32
+
+	// Slew limit (rate in V/s, step in V). A zero rate disables it.
+	slewRate, slewStep float32
+	// Serializes the ramps of the output
+	ramp sync.Mutex
+}
+
+// Return the state of output n or nil if it doesn't exist
+func (daq *OpenDAQ) output(n uint) *outputState {
+	if n < 1 || n > uint(len(daq.outputs)) {
+		return nil
+	}
+	return &daq.outputs[n-1]
+}
+
+// Record the last value written to output n
+func (daq *OpenDAQ) storeOutput(n uint, raw int, volts float32) {
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	if out := daq.output(n); out != nil {
+		out.AnalogState = AnalogState{true, raw, volts}
+	}
+}
+
+// Mark the value of output n as unknown
+func (daq *OpenDAQ) forgetOutput(n uint) {
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	if out := daq.output(n); out != nil {
+		out.Known = false
+	}
+}
+
+// Return the last voltage written to output n
+func (daq *OpenDAQ) lastVolts(n uint) (float32, bool) {
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	if out := daq.output(n); out != nil {
+		return out.Volts, out.Known
+	}
+	return 0, false
+}
+
+func (daq *OpenDAQ) slewLimit(n uint) (rate, step float32) {
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	if out := daq.output(n); out != nil {
+		return out.slewRate, out.slewStep
+	}
+	return 0, 0
+}
+
+// Set the default slew limit of output n, honoured by SetAnalog.
+// rate is the maximum rate of change in V/s and step the size of each
+// voltage step in V. A zero rate removes the limit.
+func (daq *OpenDAQ) SetSlewLimit(n uint, rate, step float32) error {
+	if rate < 0 || (rate > 0 && step <= 0) {
+		return ErrInvalidSlew
+	}
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	out := daq.output(n)
+	if out == nil {
+		return ErrInvalidOutput
+	}
+	out.slewRate, out.slewStep = rate, step
+	return nil
+}
+
+// Ramp output n from its last known voltage to val at the given rate (V/s),
+// in steps of at most step volts.
+// The ramp stops when ctx is cancelled, leaving the output at the last step
+// written. If a step fails, the last good value is written again; if that
+// also fails the output is marked as unknown.
+// Each step is queued separately, so a ramp can't run inside a transaction:
+// it would hold the device while sleeping between steps. ErrRampInTx is
+// returned instead.
+func (daq *OpenDAQ) RampAnalog(ctx context.Context, n uint, val, rate, step float32) error {
+	if rate <= 0 || step <= 0 {
+		return ErrInvalidSlew
+	}
+	if daq.held {
+		return ErrRampInTx
+	}
+	out := daq.output(n)
+	if out == nil {
+		return ErrInvalidOutput
+	}
+	out.ramp.Lock()
+	defer out.ramp.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	start, known := daq.lastVolts(n)
+	if !known {
+		return ErrUnknownOutput
+	}
+	if err := daq.checkAnalog(n, val); err != nil {
+		return err
+	}
+	if err := daq.checkInterlocks(Change{Kind: ChangeAnalog, N: n, Volts: val}); err != nil {
+		return err
+	}
+	dist := val - start
+	nSteps := int(math.Ceil(math.Abs(float64(dist / step))))
+	if nSteps == 0 {
+		return daq.rampStep(n, val, start)
+	}
+	interval := time.Duration(float64(math.Abs(float64(dist))) / float64(nSteps) /
+		float64(rate) * float64(time.Second))
+
+	last := start
+	t0 := time.Now()
+	for i := 1; i <= nSteps; i++ {
+		if i > 1 {
+			if err := sleepUntil(ctx, t0.Add(time.Duration(i-1)*interval)); err != nil {
+				return err
+			}
+		}
+		v := start + dist*float32(i)/float32(nSteps)
+		if i == nSteps {
+			v = val
+		}
+		if err := daq.rampStep(n, v, last); err != nil {
+			return err
+		}
+		last = v
+	}
+	return nil
+}
+
+// Write a step of a ramp, checking the interlocks in the same operation.
+// If the write fails, last is written again.
+func (daq *OpenDAQ) rampStep(n uint, v, last float32) error {
+	return daq.exec(PriorityOutput, func(d *OpenDAQ) error {
+		if err := d.checkInterlocks(Change{Kind: ChangeAnalog, N: n, Volts: v}); err != nil {
+			return err
+		}
+		if err := d.setAnalog(n, v); err != nil {
+			if d.setAnalog(n, last) != nil {
+				d.forgetOutput(n)
+			}
+			return err
+		}
+		return nil
+	})
+}
@@ -0,0 +1,122 @@
+package godaq
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlewLimit(t *testing.T) {
+	daq := newTestDAQ(t)
+	assert.Equal(t, ErrInvalidSlew, daq.SetSlewLimit(1, 1, 0))
+	assert.Equal(t, ErrInvalidSlew, daq.SetSlewLimit(1, -1, 0.1))
+	assert.Equal(t, ErrInvalidOutput, daq.SetSlewLimit(2, 1, 0.1))
+	assert.Nil(t, daq.SetSlewLimit(1, 1, 0.1))
+
+	rate, step := daq.slewLimit(1)
+	assert.Equal(t, float32(1), rate)
+	assert.Equal(t, float32(0.1), step)
+
+	// the ramp can't start from an unknown voltage
+	assert.Equal(t, ErrUnknownOutput, daq.SetAnalog(1, 2))
+	assert.Equal(t, ErrInvalidOutput, daq.RampAnalog(context.Background(), 2, 2, 1, 0.1))
+}
+
+// Return the raw values written to the DAC
+func dacWrites(sim *simDevice) []int16 {
+	var list []int16
+	for _, m := range sim.sent(SET_DAC) {
+		list = append(list, int16(m.Body[0])<<8|int16(m.Body[1]))
+	}
+	return list
+}
+
+func TestRampAnalog(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	assert.Nil(t, daq.SetAnalog(1, 0))
+
+	start := time.Now()
+	assert.Nil(t, daq.RampAnalog(context.Background(), 1, 1, 10, 0.25))
+	// 4 steps of 25 ms: the last one is written after 75 ms
+	assert.True(t, time.Since(start) >= 75*time.Millisecond)
+	assert.Equal(t, []int16{0, 2000, 4000, 6000, 8000}, dacWrites(sim))
+	assert.Equal(t, AnalogState{true, 8000, 1}, daq.Snapshot().Analog[0])
+
+	// Slew limit used by SetAnalog
+	assert.Nil(t, daq.SetSlewLimit(1, 10, 0.5))
+	assert.Nil(t, daq.SetAnalog(1, 0))
+	assert.Equal(t, []int16{4000, 0}, dacWrites(sim)[5:])
+}
+
+func TestRampAnalogCancel(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	assert.Nil(t, daq.SetAnalog(1, 0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, daq.RampAnalog(ctx, 1, 1, 0.5, 0.1))
+	// Steps at 0 and 200 ms
+	assert.Equal(t, []int16{0, 800, 1600}, dacWrites(sim))
+	assert.Equal(t, AnalogState{true, 1600, 0.2}, daq.Snapshot().Analog[0])
+
+	assert.Equal(t, context.Canceled, daq.RampAnalog(canceled(), 1, 1, 1, 0.1))
+}
+
+func canceled() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func TestRampAnalogFailure(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib(), WithRetries(1, 0))
+	assert.Nil(t, daq.SetAnalog(1, 0.5))
+
+	// The last good value is written again
+	sim.fail = 1
+	assert.Equal(t, ErrNakReceived, daq.RampAnalog(context.Background(), 1, 1, 100, 0.25))
+	assert.Equal(t, []int16{4000, 6000, 4000}, dacWrites(sim))
+	assert.Equal(t, AnalogState{true, 4000, 0.5}, daq.Snapshot().Analog[0])
+
+	// The output is unknown if that fails too
+	sim.fail = 2
+	assert.Equal(t, ErrNakReceived, daq.RampAnalog(context.Background(), 1, 1, 100, 0.25))
+	assert.False(t, daq.Snapshot().Analog[0].Known)
+}
+
+func TestRampInTx(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	assert.Nil(t, daq.SetAnalog(1, 0))
+	assert.Nil(t, daq.SetSlewLimit(1, 10, 0.1))
+
+	// A slow ramp holds the output while a transaction sets it
+	ramp := make(chan error, 1)
+	go func() { ramp <- daq.SetAnalog(1, 1) }()
+	for len(sim.sent(SET_DAC)) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	tx := make(chan error, 1)
+	go func() {
+		tx <- daq.Do(func(tx *Tx) error {
+			return tx.SetAnalog(1, 0.5)
+		})
+	}()
+	select {
+	case err := <-tx:
+		assert.Equal(t, ErrRampInTx, err)
+	case <-time.After(time.Second):
+		t.Fatal("transaction blocked by the ramp")
+	}
+	assert.Equal(t, ErrRampInTx, daq.Do(func(tx *Tx) error {
+		return tx.RampAnalog(context.Background(), 1, 0, 1, 0.1)
+	}))
+	select {
+	case err := <-ramp:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("ramp blocked")
+	}
+	assert.Equal(t, float32(1), daq.Snapshot().Analog[0].Volts)
+}
@@ -31,6 +31,12 @@ var (
 	ErrNakReceived   = errors.New("NAK response received")
 )
 
+// Byte stream used to talk to a device. *serial.Port implements it.
+type Transport interface {
+	io.ReadWriteCloser
+	Flush() error
+}
+
 type Message struct {
 	Number CommandNumber
 	Body   []byte
@@ -74,18 +80,62 @@ func parseResponse(b []byte) (io.Reader, error) {
 	return bytes.NewBuffer(b[4:]), nil
 }
 
+// Split the first frame from a byte stream and return its size. A frame with
+// a wrong checksum is returned with ErrChecksum. If b holds less than a whole
+// frame, ErrInvalidLength is returned and n is len(b).
+func ReadFrame(b []byte) (m Message, n int, err error) {
+	if len(b) < 4 {
+		return m, len(b), ErrInvalidLength
+	}
+	m.Number = CommandNumber(b[2])
+	n = 4 + int(b[3])
+	if n > len(b) {
+		m.Body = b[4:]
+		return m, len(b), ErrInvalidLength
+	}
+	m.Body = b[4:n]
+	if binary.BigEndian.Uint16(b[:2]) != checksum(b[2:n]) {
+		err = ErrChecksum
+	}
+	return
+}
+
 func sendCommand(ser io.ReadWriter, command *Message, respLen int) (io.Reader, error) {
+	return traceCommand(ser, command, respLen, nil, 0)
+}
+
+// Send a command like sendCommand, reporting the request and response frames
+// to a tracer (if not nil)
+func traceCommand(ser io.ReadWriter, command *Message, respLen int, t Tracer, attempt int) (io.Reader, error) {
 	data, err := command.Marshal()
 	if err != nil {
 		return nil, err
 	}
-	if _, err := ser.Write(data); err != nil {
+	_, err = ser.Write(data)
+	if t != nil {
+		t.Trace(&TraceEvent{time.Now(), TraceRequest, command.Number, command.Body, data, attempt, err})
+	}
+	if err != nil {
 		return nil, err
 	}
 	data = make([]byte, respLen+4)
-	if _, err := ser.Read(data); err != nil {
-		return nil, err
+	n, err := ser.Read(data)
+	if err == nil {
+		time.Sleep(time.Millisecond)
+	}
+	var r io.Reader
+	if err == nil {
+		r, err = parseResponse(data)
+	}
+	if t != nil {
+		ev := TraceEvent{time.Now(), TraceResponse, command.Number, nil, data[:n], attempt, err}
+		if n > 2 {
+			ev.Command = CommandNumber(data[2])
+		}
+		if n > 4 {
+			ev.Body = data[4:n]
+		}
+		t.Trace(&ev)
 	}
-	time.Sleep(time.Millisecond)
-	return parseResponse(data)
+	return r, err
 }
@@ -0,0 +1,28 @@
+package godaq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadFrame(t *testing.T) {
+	stream := []byte{0, 0x17, LED_W, 2, 2, 1, 0, 1, AIN, 0}
+
+	m, n, err := ReadFrame(stream)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, Message{LED_W, []byte{2, 1}}, m)
+
+	m, n, err = ReadFrame(stream[6:])
+	assert.Nil(t, err)
+	assert.Equal(t, 4, n)
+	assert.Equal(t, CommandNumber(AIN), m.Number)
+
+	_, _, err = ReadFrame([]byte{0, 0x18, LED_W, 2, 2, 1})
+	assert.Equal(t, ErrChecksum, err)
+
+	_, n, err = ReadFrame([]byte{0, 0x17, LED_W, 2, 2})
+	assert.Equal(t, ErrInvalidLength, err)
+	assert.Equal(t, 5, n)
+}
@@ -0,0 +1,246 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"container/heap"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrClosed = errors.New("Device closed")
+
+// Priority of an operation in the command queue. Operations with a higher
+// priority are run first; operations with the same priority run in order.
+type Priority int
+
+const (
+	PriorityRead   Priority = iota // Reads and other commands
+	PriorityOutput                 // Output writes and transactions
+	PrioritySafety                 // Safe state
+)
+
+// Result of an operation that has been queued
+type Future struct {
+	done     chan struct{}
+	err      error
+	panicked bool
+	panicVal interface{}
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func (f *Future) complete(err error) {
+	f.err = err
+	close(f.done)
+}
+
+// Return a channel closed when the operation completes
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait until the operation completes and return its error.
+// If the operation panicked, the panic is raised again in the caller.
+func (f *Future) Wait() error {
+	<-f.done
+	if f.panicked {
+		panic(f.panicVal)
+	}
+	return f.err
+}
+
+// Statistics of the command queue. Latencies are measured per operation: a
+// single command or a whole transaction.
+type QueueStats struct {
+	Depth     int           // Operations waiting
+	Completed uint64        // Operations completed
+	Wait      time.Duration // Mean time spent waiting in the queue
+	MaxWait   time.Duration // Longest time spent waiting in the queue
+	Exec      time.Duration // Mean execution time
+}
+
+// Queued operation
+type job struct {
+	prio   Priority
+	seq    uint64
+	queued time.Time
+	run    func() error
+	future *Future
+}
+
+// Heap of jobs ordered by priority and arrival
+type jobHeap []*job
+
+func (h jobHeap) Len() int { return len(h) }
+func (h jobHeap) Less(i, j int) bool {
+	if h[i].prio != h[j].prio {
+		return h[i].prio > h[j].prio
+	}
+	return h[i].seq < h[j].seq
+}
+func (h jobHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *jobHeap) Push(x interface{}) { *h = append(*h, x.(*job)) }
+func (h *jobHeap) Pop() interface{} {
+	old := *h
+	j := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return j
+}
+
+// Command queue served by a single goroutine, which owns the port
+type ioQueue struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	jobs   jobHeap
+	seq    uint64
+	closed bool
+
+	completed            uint64
+	totalWait, totalExec time.Duration
+	maxWait              time.Duration
+}
+
+// Create a queue and start its goroutine
+func newQueue() *ioQueue {
+	q := &ioQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	go q.loop()
+	return q
+}
+
+// Queue an operation. The future fails with ErrClosed if the queue has been
+// stopped.
+func (q *ioQueue) submit(prio Priority, run func() error) *Future {
+	f := newFuture()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		f.complete(ErrClosed)
+		return f
+	}
+	q.seq++
+	heap.Push(&q.jobs, &job{prio, q.seq, time.Now(), run, f})
+	q.cond.Signal()
+	return f
+}
+
+// Stop the goroutine once the queued operations have run. It doesn't wait,
+// so it can be called from an operation.
+func (q *ioQueue) stop() {
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Signal()
+	q.mu.Unlock()
+}
+
+func (q *ioQueue) loop() {
+	for {
+		q.mu.Lock()
+		for len(q.jobs) == 0 && !q.closed {
+			q.cond.Wait()
+		}
+		if len(q.jobs) == 0 {
+			q.mu.Unlock()
+			return
+		}
+		j := heap.Pop(&q.jobs).(*job)
+		q.mu.Unlock()
+
+		start := time.Now()
+		err := j.exec()
+		exe := time.Since(start)
+
+		q.mu.Lock()
+		wait := start.Sub(j.queued)
+		q.completed++
+		q.totalWait += wait
+		q.totalExec += exe
+		if wait > q.maxWait {
+			q.maxWait = wait
+		}
+		q.mu.Unlock()
+		j.future.complete(err)
+	}
+}
+
+// Run the operation, recovering from a panic so that it doesn't kill the I/O
+// goroutine. The panic is passed to the waiting goroutine by the future.
+func (j *job) exec() error {
+	j.future.panicked = true
+	defer func() {
+		if j.future.panicked {
+			j.future.panicVal = recover()
+		}
+	}()
+	err := j.run()
+	j.future.panicked = false
+	return err
+}
+
+func (q *ioQueue) stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := QueueStats{Depth: len(q.jobs), Completed: q.completed, MaxWait: q.maxWait}
+	if q.completed > 0 {
+		s.Wait = q.totalWait / time.Duration(q.completed)
+		s.Exec = q.totalExec / time.Duration(q.completed)
+	}
+	return s
+}
+
+// Return the statistics of the command queue
+func (daq *OpenDAQ) QueueStats() QueueStats {
+	return daq.queue.stats()
+}
+
+// Priority of a single command: output writes jump ahead of reads
+func commandPriority(m *Message) Priority {
+	switch m.Number {
+	case SET_DAC, SET_ANALOG, PIO_DIR, PORT_DIR, LED_W, CAPTURE_STOP, ENCODER_STOP, STREAM_STOP:
+		return PriorityOutput
+	case PIO:
+		if len(m.Body) > 1 {
+			return PriorityOutput
+		}
+	case PORT:
+		if len(m.Body) > 0 {
+			return PriorityOutput
+		}
+	}
+	return PriorityRead
+}
+
+// Queue f to run on the I/O goroutine with the device held, and return its
+// future. f receives a view of the device whose commands are sent directly.
+// Inside a transaction, f runs immediately.
+func (daq *OpenDAQ) enqueue(prio Priority, f func(held *OpenDAQ) error) *Future {
+	if daq.held {
+		fut := newFuture()
+		fut.complete(f(daq))
+		return fut
+	}
+	daq.kickWatchdog()
+	return daq.queue.submit(prio, func() error {
+		return f(&OpenDAQ{conn: daq.conn, held: true})
+	})
+}
+
+// Run f on the I/O goroutine with the device held and wait for it
+func (daq *OpenDAQ) exec(prio Priority, f func(held *OpenDAQ) error) error {
+	return daq.enqueue(prio, f).Wait()
+}
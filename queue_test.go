@@ -0,0 +1,95 @@
+package godaq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueuePriority(t *testing.T) {
+	daq, sim := newSimDAQ(t)
+	defer daq.Close()
+
+	// Hold the I/O goroutine while the other operations are queued
+	release := make(chan struct{})
+	started := make(chan struct{})
+	hold := daq.Go(func(tx *Tx) error {
+		close(started)
+		<-release
+		return nil
+	})
+	<-started
+
+	done := make(chan CommandNumber, 3)
+	go func() {
+		daq.ReadPort()
+		done <- PORT
+	}()
+	waitDepth(t, daq, 1)
+	go func() {
+		daq.SetLED(1, RED)
+		done <- LED_W
+	}()
+	waitDepth(t, daq, 2)
+	go func() {
+		daq.SetDAC(1, 0)
+		done <- SET_DAC
+	}()
+	waitDepth(t, daq, 3)
+
+	close(release)
+	assert.Nil(t, hold.Wait())
+	for i := 0; i < 3; i++ {
+		<-done
+	}
+	var order []CommandNumber
+	for _, m := range sim.requests {
+		switch m.Number {
+		case PORT, LED_W, SET_DAC:
+			order = append(order, m.Number)
+		}
+	}
+	assert.Equal(t, []CommandNumber{LED_W, SET_DAC, PORT}, order)
+
+	stats := daq.QueueStats()
+	assert.Equal(t, 0, stats.Depth)
+	assert.True(t, stats.MaxWait > 0)
+}
+
+func TestQueueClosed(t *testing.T) {
+	daq, _ := newSimDAQ(t)
+	assert.Nil(t, daq.Close())
+	assert.Equal(t, ErrClosed, daq.SetLED(1, RED))
+	_, err := daq.ReadAnalog()
+	assert.Equal(t, ErrClosed, err)
+}
+
+func TestQueuePanic(t *testing.T) {
+	daq, _ := newSimDAQ(t)
+	assert.PanicsWithValue(t, "boom", func() {
+		daq.Do(func(tx *Tx) error { panic("boom") })
+	})
+	// The I/O goroutine is still running
+	assert.Nil(t, daq.SetLED(1, RED))
+}
+
+func TestCommandPriority(t *testing.T) {
+	assert.Equal(t, PriorityRead, commandPriority(&Message{Number: PIO, Body: []byte{1}}))
+	assert.Equal(t, PriorityOutput, commandPriority(&Message{Number: PIO, Body: []byte{1, 1}}))
+	assert.Equal(t, PriorityRead, commandPriority(&Message{Number: PORT}))
+	assert.Equal(t, PriorityOutput, commandPriority(&Message{Number: PORT, Body: []byte{1}}))
+	assert.Equal(t, PriorityOutput, commandPriority(&Message{Number: SET_ANALOG}))
+	assert.Equal(t, PriorityRead, commandPriority(&Message{Number: AIN}))
+}
+
+// Wait until the queue holds n operations
+func waitDepth(t *testing.T, daq *OpenDAQ, n int) {
+	deadline := time.Now().Add(time.Second)
+	for daq.QueueStats().Depth < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("queue depth %d, want %d", daq.QueueStats().Depth, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
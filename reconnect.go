@@ -0,0 +1,180 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+var ErrDisconnected = errors.New("Device disconnected")
+
+// Kind of connection event
+type ConnEventKind uint8
+
+const (
+	Disconnected ConnEventKind = iota
+	Reconnected
+	ReconnectFailed
+)
+
+// Connection event reported by the automatic reconnection.
+// Port is the port in use after the event.
+type ConnEvent struct {
+	Kind ConnEventKind
+	Port string
+	Err  error
+}
+
+// Settings of the automatic reconnection
+type ReconnectConfig struct {
+	Timeout  time.Duration   // Time to keep looking for the device (default: 30 s)
+	Interval time.Duration   // Time between attempts (default: 1 s)
+	OnEvent  func(ConnEvent) // Called on disconnection and reconnection from another goroutine (optional)
+}
+
+// Enable the automatic reconnection.
+// When a command fails because the port is gone, it returns ErrDisconnected
+// and the device is searched in the background in all the serial ports by
+// its serial number. Once found, the calibration is reloaded and the last
+// known state is restored (see Restore). The commands sent in the meantime
+// fail with ErrDisconnected. If the device isn't found before the timeout,
+// the next command starts a new search.
+func (daq *OpenDAQ) EnableAutoReconnect(cfg ReconnectConfig) {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	daq.safeLock.Lock()
+	defer daq.safeLock.Unlock()
+	daq.reconnect = &cfg
+}
+
+// Disable the automatic reconnection
+func (daq *OpenDAQ) DisableAutoReconnect() {
+	daq.safeLock.Lock()
+	defer daq.safeLock.Unlock()
+	daq.reconnect = nil
+}
+
+func (daq *OpenDAQ) reconnectConfig() *ReconnectConfig {
+	daq.safeLock.Lock()
+	defer daq.safeLock.Unlock()
+	return daq.reconnect
+}
+
+// Check if an I/O error means that the port is gone
+func (daq *OpenDAQ) shouldReconnect(err error) bool {
+	// A custom transport can't be reopened
+	if daq.reconnectConfig() == nil || daq.info.Serial == "" || daq.cfg.transport != nil {
+		return false
+	}
+	for _, e := range []error{syscall.EIO, syscall.ENXIO, syscall.ENODEV, syscall.EBADF, os.ErrClosed} {
+		if errors.Is(err, e) {
+			return true
+		}
+	}
+	// A read timeout and a hung-up port both return EOF
+	_, e := os.Stat(daq.info.Port)
+	return os.IsNotExist(e)
+}
+
+// Used to replace the device search in the tests. It's set in init to break
+// the initialization cycle through New.
+var findDevice func(serial string, opts []Option) *OpenDAQ
+
+func init() {
+	findDevice = findSerial
+}
+
+// Close the lost port and start searching the device. Called with the device
+// held.
+func (daq *OpenDAQ) startReconnect() {
+	cfg := daq.reconnectConfig()
+	if cfg == nil {
+		return
+	}
+	port := daq.info.Port
+	unregisterPort(port)
+	daq.closePort()
+	daq.reconnecting = true
+	go daq.reconnectLoop(cfg, findDevice, daq.info.Serial, port, daq.Snapshot())
+}
+
+// Search the device until it's found or the timeout expires, restore its
+// state and replace the serial port. It runs outside the command queue, so
+// the safe state and the other commands aren't blocked meanwhile, and the
+// event callback can use the device.
+func (daq *OpenDAQ) reconnectLoop(cfg *ReconnectConfig, find func(string, []Option) *OpenDAQ,
+	serial, port string, state State) {
+	notify := func(kind ConnEventKind, port string, err error) {
+		if cfg.OnEvent != nil {
+			cfg.OnEvent(ConnEvent{kind, port, err})
+		}
+	}
+	notify(Disconnected, port, nil)
+	deadline := time.Now().Add(cfg.Timeout)
+	for {
+		// Stop if the device is closed
+		if daq.reconnectConfig() == nil {
+			return
+		}
+		if dev := find(serial, daq.opts); dev != nil {
+			restoreErr := dev.Restore(state)
+			dev.queue.stop()
+			err := daq.exec(PrioritySafety, func(d *OpenDAQ) error {
+				d.calibLock.Lock()
+				d.calib = dev.calib
+				d.calibLock.Unlock()
+				d.ser, d.lock, d.info = dev.ser, dev.lock, dev.info
+				d.reconnecting = false
+				return nil
+			})
+			if err != nil {
+				unregisterPort(dev.info.Port)
+				dev.closePort()
+				return
+			}
+			notify(Reconnected, dev.info.Port, restoreErr)
+			return
+		}
+		if time.Now().Add(cfg.Interval).After(deadline) {
+			daq.exec(PrioritySafety, func(d *OpenDAQ) error {
+				d.reconnecting = false
+				return nil
+			})
+			notify(ReconnectFailed, port, ErrDisconnected)
+			return
+		}
+		time.Sleep(cfg.Interval)
+	}
+}
+
+// Open the device with the given serial number. Returns nil if it's not found.
+func findSerial(serialNum string, opts []Option) *OpenDAQ {
+	devices, _ := ListDevicePorts()
+	for _, dp := range devices {
+		if dp.Serial != serialNum {
+			continue
+		}
+		if dev, err := New(dp.Port, opts...); err == nil {
+			return dev
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package godaq
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShouldReconnect(t *testing.T) {
+	f, err := ioutil.TempFile("", "ttyUSB")
+	assert.Nil(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	daq := newTestDAQ(t)
+	daq.info = DevicePort{Port: f.Name(), Serial: "0042"}
+	assert.False(t, daq.shouldReconnect(syscall.EIO))
+
+	daq.EnableAutoReconnect(ReconnectConfig{})
+	assert.True(t, daq.shouldReconnect(&os.PathError{Op: "write", Path: f.Name(), Err: syscall.EIO}))
+	assert.False(t, daq.shouldReconnect(io.EOF))
+	assert.False(t, daq.shouldReconnect(ErrChecksum))
+
+	os.Remove(f.Name())
+	assert.True(t, daq.shouldReconnect(io.EOF))
+
+	daq.DisableAutoReconnect()
+	assert.False(t, daq.shouldReconnect(io.EOF))
+}
+
+// Open a simulated device that can be reconnected. The port fails with EIO
+// when sim.err is set.
+func newReconnectDAQ(t *testing.T, cfg ReconnectConfig) (*OpenDAQ, *simDevice, chan ConnEvent) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	// Handle the simulator as a serial port
+	daq.cfg.transport = nil
+	events := make(chan ConnEvent, 4)
+	cfg.OnEvent = func(ev ConnEvent) { events <- ev }
+	daq.EnableAutoReconnect(cfg)
+	return daq, sim, events
+}
+
+// Replace the device search for the duration of a test
+func stubFindDevice(t *testing.T, f func(serial string) *OpenDAQ) {
+	findDevice = func(serial string, opts []Option) *OpenDAQ { return f(serial) }
+	t.Cleanup(func() { findDevice = findSerial })
+}
+
+func waitEvent(t *testing.T, events chan ConnEvent) ConnEvent {
+	select {
+	case ev := <-events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("no connection event")
+		return ConnEvent{}
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	daq, sim, events := newReconnectDAQ(t, ReconnectConfig{Timeout: 5 * time.Second, Interval: time.Millisecond})
+	assert.Nil(t, daq.SetAnalog(1, 1))
+	assert.Nil(t, daq.SetPIO(2, true))
+
+	// The device is found on the third attempt
+	newSim := &simDevice{model: ModelMId, serial: 42}
+	release := make(chan struct{})
+	var searches []string
+	stubFindDevice(t, func(serial string) *OpenDAQ {
+		searches = append(searches, serial)
+		if len(searches) < 3 {
+			return nil
+		}
+		<-release
+		dev, err := New("sim2", WithTransport(newSim), WithIdentityCalib())
+		assert.Nil(t, err)
+		return dev
+	})
+
+	sim.err = &os.PathError{Op: "write", Path: "sim", Err: syscall.EIO}
+	assert.Equal(t, ErrDisconnected, daq.SetLED(1, RED))
+	assert.Equal(t, ConnEvent{Disconnected, "sim", nil}, waitEvent(t, events))
+
+	// Commands fail fast while the device is searched
+	assert.Equal(t, ErrDisconnected, daq.SetLED(1, RED))
+	close(release)
+	assert.Equal(t, ConnEvent{Reconnected, "sim2", nil}, waitEvent(t, events))
+	assert.Equal(t, []string{"0042", "0042", "0042"}, searches)
+
+	// The state is restored on the new port, which is used from then on
+	assert.Equal(t, []Message{{PIO, []byte{2, 1}}}, newSim.sent(PIO))
+	assert.Equal(t, []Message{{SET_DAC, []byte{0x1f, 0x40, 1}}}, newSim.sent(SET_DAC))
+	assert.Nil(t, daq.SetLED(1, GREEN))
+	assert.Len(t, newSim.sent(LED_W), 1)
+	assert.Equal(t, "sim2", daq.info.Port)
+}
+
+func TestReconnectGiveUp(t *testing.T) {
+	daq, sim, events := newReconnectDAQ(t, ReconnectConfig{Timeout: 50 * time.Millisecond, Interval: 10 * time.Millisecond})
+	searches := make(chan string, 100)
+	stubFindDevice(t, func(serial string) *OpenDAQ {
+		searches <- serial
+		return nil
+	})
+
+	sim.err = syscall.EIO
+	assert.Equal(t, ErrDisconnected, daq.SetLED(1, RED))
+	assert.Equal(t, Disconnected, waitEvent(t, events).Kind)
+	assert.Equal(t, ConnEvent{ReconnectFailed, "sim", ErrDisconnected}, waitEvent(t, events))
+	assert.True(t, len(searches) > 1)
+
+	// The next command starts a new search
+	assert.Equal(t, ErrDisconnected, daq.SetLED(1, RED))
+	assert.Equal(t, Disconnected, waitEvent(t, events).Kind)
+	assert.Equal(t, ReconnectFailed, waitEvent(t, events).Kind)
+}
+
+func TestReconnectSafeState(t *testing.T) {
+	daq, sim, events := newReconnectDAQ(t, ReconnectConfig{Timeout: 5 * time.Second, Interval: 10 * time.Millisecond})
+	assert.Nil(t, daq.SetSafeState(&SafeState{LED: map[uint]Color{1: RED}}))
+	release := make(chan struct{})
+	defer close(release)
+	stubFindDevice(t, func(serial string) *OpenDAQ {
+		<-release
+		return nil
+	})
+
+	sim.err = syscall.EIO
+	assert.Equal(t, ErrDisconnected, daq.SetLED(1, GREEN))
+	waitEvent(t, events)
+	// The safe state isn't blocked by the search
+	done := make(chan error, 1)
+	go func() { done <- daq.ApplySafeState() }()
+	select {
+	case err := <-done:
+		assert.Equal(t, ErrDisconnected, err)
+	case <-time.After(time.Second):
+		t.Fatal("safe state blocked while reconnecting")
+	}
+}
+
+func TestReconnectEventCallback(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	daq.cfg.transport = nil
+	stubFindDevice(t, func(serial string) *OpenDAQ { return nil })
+	closed := make(chan error, 1)
+	daq.EnableAutoReconnect(ReconnectConfig{Timeout: 5 * time.Second, Interval: 10 * time.Millisecond,
+		OnEvent: func(ev ConnEvent) {
+			// The callback can use the device
+			daq.Snapshot()
+			if ev.Kind == Disconnected {
+				closed <- daq.Close()
+			}
+		}})
+
+	sim.err = syscall.EIO
+	assert.Equal(t, ErrDisconnected, daq.SetLED(1, RED))
+	select {
+	case err := <-closed:
+		assert.Nil(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("event callback blocked")
+	}
+	assert.Equal(t, ErrClosed, daq.SetLED(1, RED))
+}
@@ -0,0 +1,217 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Request/response pair stored in a recording, one JSON object per line
+type exchange struct {
+	At       time.Duration `json:"at"` // Time of the request since the start
+	Request  string        `json:"request"`
+	Response string        `json:"response"`
+	Latency  time.Duration `json:"latency"` // Time until the response was read
+}
+
+// Transport wrapper saving the exchanged frames to a file that can be
+// replayed with Replay
+type Recorder struct {
+	mu      sync.Mutex
+	t       Transport
+	enc     *json.Encoder
+	start   time.Time
+	sent    time.Time
+	pending *exchange
+	resp    []byte
+	err     error
+}
+
+// Wrap a transport, writing the recording to w
+func NewRecorder(t Transport, w io.Writer) *Recorder {
+	return &Recorder{t: t, enc: json.NewEncoder(w), start: time.Now()}
+}
+
+func (r *Recorder) Write(b []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.save()
+	r.sent = time.Now()
+	r.pending = &exchange{At: r.sent.Sub(r.start), Request: hex.EncodeToString(b)}
+	return r.t.Write(b)
+}
+
+func (r *Recorder) Read(b []byte) (int, error) {
+	n, err := r.t.Read(b)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending != nil && n > 0 {
+		r.resp = append(r.resp, b[:n]...)
+		r.pending.Latency = time.Since(r.sent)
+	}
+	return n, err
+}
+
+func (r *Recorder) Flush() error {
+	r.mu.Lock()
+	r.save()
+	r.mu.Unlock()
+	return r.t.Flush()
+}
+
+// Close the transport and return the first error writing the recording, if
+// any
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	r.save()
+	err := r.err
+	r.mu.Unlock()
+	if e := r.t.Close(); e != nil {
+		return e
+	}
+	return err
+}
+
+// Write the pending exchange
+func (r *Recorder) save() {
+	if r.pending == nil {
+		return
+	}
+	r.pending.Response = hex.EncodeToString(r.resp)
+	if err := r.enc.Encode(r.pending); err != nil && r.err == nil {
+		r.err = err
+	}
+	r.pending, r.resp = nil, nil
+}
+
+// Error returned by Replay when the request differs from the recording
+type UnexpectedRequestError struct {
+	Index     int // Index of the exchange in the recording
+	Got, Want []byte
+}
+
+func (e *UnexpectedRequestError) Error() string {
+	if e.Want == nil {
+		return fmt.Sprintf("Unexpected request % x after the end of the recording", e.Got)
+	}
+	return fmt.Sprintf("Unexpected request % x at exchange %d, want % x", e.Got, e.Index, e.Want)
+}
+
+type replayExchange struct {
+	req, resp []byte
+	latency   time.Duration
+}
+
+// Transport serving the responses of a recording. Requests must match the
+// recording in order.
+type Replay struct {
+	mu        sync.Mutex
+	exchanges []replayExchange
+	next      int
+	realTime  bool
+	resp      bytes.Buffer
+	ready     time.Time
+}
+
+// Load a recording written by Recorder. With realTime, each response is
+// delayed by the time the device took to answer.
+func LoadReplay(r io.Reader, realTime bool) (*Replay, error) {
+	rp := &Replay{realTime: realTime}
+	s := bufio.NewScanner(r)
+	for line := 1; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var ex exchange
+		if err := json.Unmarshal(s.Bytes(), &ex); err != nil {
+			return nil, fmt.Errorf("Line %d: %v", line, err)
+		}
+		req, err := hex.DecodeString(ex.Request)
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %v", line, err)
+		}
+		resp, err := hex.DecodeString(ex.Response)
+		if err != nil {
+			return nil, fmt.Errorf("Line %d: %v", line, err)
+		}
+		rp.exchanges = append(rp.exchanges, replayExchange{req, resp, ex.Latency})
+	}
+	return rp, s.Err()
+}
+
+// Load a recording from a file
+func OpenReplay(path string, realTime bool) (*Replay, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadReplay(f, realTime)
+}
+
+func (rp *Replay) Write(b []byte) (int, error) {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	rp.resp.Reset()
+	if rp.next >= len(rp.exchanges) {
+		return 0, &UnexpectedRequestError{Index: rp.next, Got: append([]byte(nil), b...)}
+	}
+	ex := rp.exchanges[rp.next]
+	if !bytes.Equal(b, ex.req) {
+		return 0, &UnexpectedRequestError{rp.next, append([]byte(nil), b...), ex.req}
+	}
+	rp.next++
+	rp.resp.Write(ex.resp)
+	rp.ready = time.Now()
+	if rp.realTime {
+		rp.ready = rp.ready.Add(ex.latency)
+	}
+	return len(b), nil
+}
+
+// Read the response to the last request. EOF is returned if there is no
+// response left, as a serial port does on timeout.
+func (rp *Replay) Read(b []byte) (int, error) {
+	rp.mu.Lock()
+	ready := rp.ready
+	rp.mu.Unlock()
+	time.Sleep(time.Until(ready))
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return rp.resp.Read(b)
+}
+
+func (rp *Replay) Flush() error {
+	rp.mu.Lock()
+	rp.resp.Reset()
+	rp.mu.Unlock()
+	return nil
+}
+
+func (rp *Replay) Close() error { return nil }
+
+// Return the number of exchanges not replayed yet
+func (rp *Replay) Remaining() int {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	return len(rp.exchanges) - rp.next
+}
@@ -0,0 +1,62 @@
+package godaq
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Record a short session with the simulated device
+func recordSession(t *testing.T) []byte {
+	var rec bytes.Buffer
+	sim := &simDevice{model: ModelMId, serial: 42, adc: -16384}
+	daq, err := New("sim", WithTransport(sim), WithIdentityCalib(), WithRecording(&rec))
+	assert.Nil(t, err)
+	assert.Nil(t, daq.SetLED(1, GREEN))
+	v, err := daq.ReadChannel(1, 0, 1, 10)
+	assert.Nil(t, err)
+	assert.InDelta(t, 2.048, v, 1e-4)
+	assert.Nil(t, daq.Close())
+	return rec.Bytes()
+}
+
+func TestReplay(t *testing.T) {
+	rec := recordSession(t)
+	assert.Equal(t, 4, bytes.Count(rec, []byte("\n")))
+
+	rp, err := LoadReplay(bytes.NewReader(rec), false)
+	assert.Nil(t, err)
+	daq, err := New("replay", WithTransport(rp), WithIdentityCalib())
+	assert.Nil(t, err)
+	assert.Nil(t, daq.SetLED(1, GREEN))
+	v, err := daq.ReadChannel(1, 0, 1, 10)
+	assert.Nil(t, err)
+	assert.InDelta(t, 2.048, v, 1e-4)
+	assert.Equal(t, 0, rp.Remaining())
+}
+
+func TestReplayUnexpected(t *testing.T) {
+	rp, err := LoadReplay(bytes.NewReader(recordSession(t)), false)
+	assert.Nil(t, err)
+	daq, err := New("replay", WithTransport(rp), WithIdentityCalib(), WithRetries(1, 0))
+	assert.Nil(t, err)
+
+	err = daq.SetLED(1, RED)
+	if assert.IsType(t, &UnexpectedRequestError{}, err) {
+		e := err.(*UnexpectedRequestError)
+		assert.Equal(t, 1, e.Index)
+		assert.Equal(t, []byte{0, 0x17, LED_W, 2, 2, 1}, e.Got)
+	}
+}
+
+func TestReplayRealTime(t *testing.T) {
+	rec := []byte(`{"at":0,"request":"001712020201","response":"001712020201","latency":20000000}` + "\n")
+	rp, err := LoadReplay(bytes.NewReader(rec), true)
+	assert.Nil(t, err)
+	start := time.Now()
+	_, err = sendCommand(rp, &Message{LED_W, []byte{2, 1}}, 2)
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) >= 20*time.Millisecond)
+}
@@ -0,0 +1,201 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Outputs written when the device is closed, when the process is signalled
+// (see CloseOnSignal) or when the watchdog expires (see SetWatchdog).
+// Outputs not present in the maps are left untouched.
+type SafeState struct {
+	Analog map[uint]float32 // Output number -> volts
+	PIO    map[uint]bool    // PIO number -> value
+	PIODir map[uint]bool    // PIO number -> direction (true: output)
+	LED    map[uint]Color   // LED number -> color
+}
+
+// Set the safe state of the device. A nil state disables it.
+// The analog voltages are checked against the output limits.
+func (daq *OpenDAQ) SetSafeState(s *SafeState) error {
+	if s != nil {
+		for _, n := range sortedKeys(s.Analog) {
+			if err := daq.checkAnalog(n, s.Analog[n]); err != nil {
+				return err
+			}
+		}
+		for n := range s.PIO {
+			if n < 1 || n > daq.NPIOs {
+				return ErrInvalidPIO
+			}
+		}
+		for n := range s.PIODir {
+			if n < 1 || n > daq.NPIOs {
+				return ErrInvalidPIO
+			}
+		}
+		for n, c := range s.LED {
+			if n < 1 || n > daq.NLeds || c > YELLOW {
+				return ErrInvalidLed
+			}
+		}
+	}
+	daq.safeLock.Lock()
+	defer daq.safeLock.Unlock()
+	daq.safe = s
+	return nil
+}
+
+// Write the safe state to the device: the analog outputs, the PIO values,
+// the PIO directions and the LEDs, in this order.
+// Slew limits and interlocks are bypassed. All the outputs are written even
+// if some of them fail; the first error is returned.
+func (daq *OpenDAQ) ApplySafeState() error {
+	daq.safeLock.Lock()
+	s := daq.safe
+	daq.safeLock.Unlock()
+	if s == nil {
+		return nil
+	}
+
+	// The whole safe state jumps ahead of the queued commands
+	return daq.exec(PrioritySafety, func(d *OpenDAQ) error {
+		var firstErr error
+		check := func(err error) {
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		// The analog outputs go first, then the PIOs. The PIO values are
+		// written before the directions to avoid glitches on new outputs.
+		for _, n := range sortedKeys(s.Analog) {
+			check(d.setAnalog(n, s.Analog[n]))
+		}
+		for _, n := range sortedKeys(s.PIO) {
+			check(d.writePIO(n, s.PIO[n]))
+		}
+		for _, n := range sortedKeys(s.PIODir) {
+			check(d.SetPIODir(n, s.PIODir[n]))
+		}
+		for _, n := range sortedKeys(s.LED) {
+			check(d.SetLED(n, s.LED[n]))
+		}
+		return firstErr
+	})
+}
+
+// Apply the safe state and close the device when the process receives one of
+// the given signals (SIGINT and SIGTERM by default). The signal is then
+// delivered again so the process terminates as it would have done.
+// The returned function removes the handler.
+func (daq *OpenDAQ) CloseOnSignal(sigs ...os.Signal) (stop func()) {
+	if len(sigs) == 0 {
+		sigs = []os.Signal{os.Interrupt, syscall.SIGTERM}
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		select {
+		case sig := <-ch:
+			daq.Close()
+			signal.Stop(ch)
+			if p, err := os.FindProcess(os.Getpid()); err != nil || p.Signal(sig) != nil {
+				os.Exit(1)
+			}
+		case <-done:
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
+
+// Start a host-side watchdog. If no command is sent to the device for the
+// given timeout, the safe state is applied and onTrip (if not nil) is called
+// with the result. The watchdog is re-armed by the next command.
+// A zero timeout stops the watchdog.
+func (daq *OpenDAQ) SetWatchdog(timeout time.Duration, onTrip func(error)) {
+	daq.safeLock.Lock()
+	defer daq.safeLock.Unlock()
+	if daq.wdTimer != nil {
+		daq.wdTimer.Stop()
+		daq.wdTimer = nil
+	}
+	if timeout <= 0 {
+		return
+	}
+	daq.wdTimeout = timeout
+	daq.wdOnTrip = onTrip
+	daq.wdTimer = time.AfterFunc(timeout, daq.tripWatchdog)
+}
+
+func (daq *OpenDAQ) kickWatchdog() {
+	daq.safeLock.Lock()
+	defer daq.safeLock.Unlock()
+	if daq.wdTimer != nil && !daq.wdApplying {
+		daq.wdTimer.Reset(daq.wdTimeout)
+	}
+}
+
+func (daq *OpenDAQ) tripWatchdog() {
+	daq.safeLock.Lock()
+	if daq.wdTimer == nil {
+		daq.safeLock.Unlock()
+		return
+	}
+	daq.wdApplying = true
+	onTrip := daq.wdOnTrip
+	daq.safeLock.Unlock()
+
+	err := daq.ApplySafeState()
+
+	daq.safeLock.Lock()
+	daq.wdApplying = false
+	daq.safeLock.Unlock()
+	if onTrip != nil {
+		onTrip(err)
+	}
+}
+
+// Return the keys of a map indexed by output number in ascending order
+func sortedKeys(m interface{}) []uint {
+	var keys []uint
+	switch m := m.(type) {
+	case map[uint]float32:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[uint]bool:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[uint]Color:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
@@ -0,0 +1,29 @@
+package godaq
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseOnSignal(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	assert.Nil(t, daq.SetSafeState(&testSafeState))
+	// SIGWINCH is ignored by default, so delivering it again is harmless
+	stop := daq.CloseOnSignal(syscall.SIGWINCH)
+	defer stop()
+	p, _ := os.FindProcess(os.Getpid())
+	assert.Nil(t, p.Signal(syscall.SIGWINCH))
+
+	// Poll with a command that isn't part of the safe state
+	var err error
+	for i := 0; i < 100 && err != ErrClosed; i++ {
+		time.Sleep(10 * time.Millisecond)
+		_, err = daq.ReadPort()
+	}
+	assert.Equal(t, ErrClosed, err)
+	assert.Equal(t, testSafeStateWrites, safeStateWrites(sim))
+}
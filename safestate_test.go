@@ -0,0 +1,90 @@
+package godaq
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetSafeState(t *testing.T) {
+	daq := newTestDAQ(t)
+	assert.Equal(t, ErrInvalidPIO, daq.SetSafeState(&SafeState{PIO: map[uint]bool{7: false}}))
+	assert.Equal(t, ErrInvalidLed, daq.SetSafeState(&SafeState{LED: map[uint]Color{1: 4}}))
+
+	daq.SetOutputLimits(1, 0, 1)
+	assert.IsType(t, &LimitError{}, daq.SetSafeState(&SafeState{Analog: map[uint]float32{1: 2}}))
+
+	// an empty safe state doesn't send any command
+	assert.Nil(t, daq.SetSafeState(&SafeState{}))
+	assert.Nil(t, daq.ApplySafeState())
+}
+
+func TestWatchdogTrip(t *testing.T) {
+	daq := newTestDAQ(t)
+	tripped := make(chan error, 1)
+	daq.SetWatchdog(time.Millisecond, func(err error) { tripped <- err })
+	select {
+	case err := <-tripped:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("watchdog not tripped")
+	}
+	daq.SetWatchdog(0, nil)
+}
+
+var testSafeState = SafeState{
+	Analog: map[uint]float32{1: 0},
+	PIO:    map[uint]bool{1: false, 2: true},
+	PIODir: map[uint]bool{1: true, 2: true},
+	LED:    map[uint]Color{1: RED},
+}
+
+// Return the commands that write the safe state, in the order they were sent
+func safeStateWrites(sim *simDevice) []Message {
+	sim.Lock()
+	defer sim.Unlock()
+	var list []Message
+	for _, m := range sim.requests {
+		switch m.Number {
+		case SET_DAC, PIO, PIO_DIR, LED_W:
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
+var testSafeStateWrites = []Message{
+	{SET_DAC, []byte{0, 0, 1}},
+	{PIO, []byte{1, 0}},
+	{PIO, []byte{2, 1}},
+	{PIO_DIR, []byte{1, 1}},
+	{PIO_DIR, []byte{2, 1}},
+	{LED_W, []byte{byte(RED), 1}},
+}
+
+func TestCloseSafeState(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	assert.Nil(t, daq.SetSafeState(&testSafeState))
+	assert.Nil(t, daq.Close())
+	assert.Equal(t, testSafeStateWrites, safeStateWrites(sim))
+}
+
+func TestWatchdogSafeState(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	assert.Nil(t, daq.SetSafeState(&testSafeState))
+	tripped := make(chan error, 1)
+	daq.SetWatchdog(10*time.Millisecond, func(err error) { tripped <- err })
+	select {
+	case err := <-tripped:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("watchdog not tripped")
+	}
+	daq.SetWatchdog(0, nil)
+	assert.Equal(t, testSafeStateWrites, safeStateWrites(sim))
+}
+
+func TestSortedKeys(t *testing.T) {
+	assert.Equal(t, []uint{1, 2, 5}, sortedKeys(map[uint]bool{5: true, 1: false, 2: true}))
+}
@@ -0,0 +1,210 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Action executed by a sequence step
+type ActionKind uint8
+
+const (
+	ActionSetAnalog ActionKind = iota
+	ActionSetPIO
+	ActionSetPort
+	ActionWait
+)
+
+var actionNames = map[string]ActionKind{
+	"analog": ActionSetAnalog,
+	"pio":    ActionSetPIO,
+	"port":   ActionSetPort,
+	"wait":   ActionWait,
+}
+
+// A single step of an output sequence. Only the fields used by the action
+// are taken into account.
+type Step struct {
+	Action ActionKind
+	N      uint          // Output number (ActionSetAnalog) or PIO number (ActionSetPIO)
+	Volts  float32       // ActionSetAnalog
+	Value  bool          // ActionSetPIO
+	Port   uint8         // ActionSetPort
+	Wait   time.Duration // ActionWait
+}
+
+type Sequence []Step
+
+// Outputs that can be driven by a Player. *OpenDAQ satisfies this interface.
+type OutputSetter interface {
+	SetAnalog(n uint, val float32) error
+	SetPIO(n uint, value bool) error
+	SetPort(value uint8) error
+}
+
+// Timing of an executed step.
+// The times are measured from the start of the run using the monotonic clock.
+type StepResult struct {
+	Loop, Index int
+	Scheduled   time.Duration
+	Actual      time.Duration
+	Err         error
+}
+
+// Return the timing error of the step
+func (r StepResult) Lag() time.Duration {
+	return r.Actual - r.Scheduled
+}
+
+// Software-timed sequence player.
+// The schedule is absolute: the time spent executing the actions does not
+// delay the following steps, so the timing errors don't accumulate.
+type Player struct {
+	Out    OutputSetter
+	Seq    Sequence
+	Loops  int              // Number of times the sequence is played (0: forever)
+	OnStep func(StepResult) // Called after each step (optional)
+}
+
+// Play the sequence until it finishes, an action fails or ctx is cancelled
+func (p *Player) Run(ctx context.Context) error {
+	start := time.Now()
+	var sched time.Duration
+	for loop := 0; p.Loops <= 0 || loop < p.Loops; loop++ {
+		for i, step := range p.Seq {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			var err error
+			if step.Action == ActionWait {
+				sched += step.Wait
+				err = sleepUntil(ctx, start.Add(sched))
+			} else {
+				err = p.exec(step)
+			}
+			res := StepResult{loop, i, sched, time.Since(start), err}
+			if p.OnStep != nil {
+				p.OnStep(res)
+			}
+			if err != nil && err == ctx.Err() {
+				return err
+			} else if err != nil {
+				return fmt.Errorf("Sequence step %d: %w", i, err)
+			}
+		}
+		if len(p.Seq) == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+func (p *Player) exec(step Step) error {
+	switch step.Action {
+	case ActionSetAnalog:
+		return p.Out.SetAnalog(step.N, step.Volts)
+	case ActionSetPIO:
+		return p.Out.SetPIO(step.N, step.Value)
+	case ActionSetPort:
+		return p.Out.SetPort(step.Port)
+	}
+	return fmt.Errorf("Unknown action %d", step.Action)
+}
+
+func sleepUntil(ctx context.Context, t time.Time) error {
+	timer := time.NewTimer(time.Until(t))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Parse a sequence in CSV format. Each record is an action followed by its
+// arguments. Lines starting with '#' are ignored.
+//
+//	analog,<output>,<volts>
+//	pio,<pio>,<0|1>
+//	port,<value>
+//	wait,<duration>    (e.g. 250ms, 1.5s)
+func ParseSequenceCSV(r io.Reader) (Sequence, error) {
+	cr := csv.NewReader(r)
+	cr.Comment = '#'
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	var seq Sequence
+	for n := 1; ; n++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			return seq, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		step, err := parseStep(rec)
+		if err != nil {
+			return nil, fmt.Errorf("Record %d: %w", n, err)
+		}
+		seq = append(seq, step)
+	}
+}
+
+func parseStep(rec []string) (step Step, err error) {
+	action, ok := actionNames[strings.ToLower(strings.TrimSpace(rec[0]))]
+	if !ok {
+		return step, fmt.Errorf("Unknown action %q", rec[0])
+	}
+	nargs := map[ActionKind]int{ActionSetAnalog: 2, ActionSetPIO: 2, ActionSetPort: 1, ActionWait: 1}
+	if len(rec)-1 != nargs[action] {
+		return step, fmt.Errorf("Action %q needs %d arguments", rec[0], nargs[action])
+	}
+	step.Action = action
+	args := rec[1:]
+	for i := range args {
+		args[i] = strings.TrimSpace(args[i])
+	}
+
+	switch action {
+	case ActionSetAnalog, ActionSetPIO:
+		var n uint64
+		if n, err = strconv.ParseUint(args[0], 10, 8); err != nil {
+			return
+		}
+		step.N = uint(n)
+		if action == ActionSetAnalog {
+			var v float64
+			v, err = strconv.ParseFloat(args[1], 32)
+			step.Volts = float32(v)
+		} else {
+			step.Value, err = strconv.ParseBool(args[1])
+		}
+	case ActionSetPort:
+		var v uint64
+		v, err = strconv.ParseUint(args[0], 0, 8)
+		step.Port = uint8(v)
+	case ActionWait:
+		step.Wait, err = time.ParseDuration(args[0])
+	}
+	return
+}
@@ -0,0 +1,81 @@
+package godaq
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeOutputs struct {
+	calls []string
+}
+
+func (f *fakeOutputs) SetAnalog(n uint, val float32) error {
+	f.calls = append(f.calls, "analog")
+	return nil
+}
+
+func (f *fakeOutputs) SetPIO(n uint, value bool) error {
+	f.calls = append(f.calls, "pio")
+	return nil
+}
+
+func (f *fakeOutputs) SetPort(value uint8) error {
+	f.calls = append(f.calls, "port")
+	return nil
+}
+
+func TestParseSequenceCSV(t *testing.T) {
+	csv := `# comment
+analog, 1, 2.5
+pio,3,1
+port,0x2a
+wait,10ms
+`
+	seq, err := ParseSequenceCSV(strings.NewReader(csv))
+	assert.Nil(t, err)
+	assert.Equal(t, Sequence{
+		{Action: ActionSetAnalog, N: 1, Volts: 2.5},
+		{Action: ActionSetPIO, N: 3, Value: true},
+		{Action: ActionSetPort, Port: 42},
+		{Action: ActionWait, Wait: 10 * time.Millisecond},
+	}, seq)
+
+	_, err = ParseSequenceCSV(strings.NewReader("analog,1,2\nblink,1\n"))
+	assert.EqualError(t, err, `Record 2: Unknown action "blink"`)
+	_, err = ParseSequenceCSV(strings.NewReader("wait\n"))
+	assert.NotNil(t, err)
+}
+
+func TestPlayerRun(t *testing.T) {
+	out := &fakeOutputs{}
+	var results []StepResult
+	p := Player{
+		Out: out,
+		Seq: Sequence{
+			{Action: ActionSetPIO, N: 1, Value: true},
+			{Action: ActionWait, Wait: 2 * time.Millisecond},
+			{Action: ActionSetAnalog, N: 1, Volts: 1},
+		},
+		Loops:  2,
+		OnStep: func(r StepResult) { results = append(results, r) },
+	}
+	assert.Nil(t, p.Run(context.Background()))
+	assert.Equal(t, []string{"pio", "analog", "pio", "analog"}, out.calls)
+	assert.Len(t, results, 6)
+	assert.Equal(t, 4*time.Millisecond, results[4].Scheduled)
+	assert.True(t, results[4].Lag() >= 0)
+}
+
+func TestPlayerCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+	p := Player{
+		Out: &fakeOutputs{},
+		Seq: Sequence{{Action: ActionWait, Wait: time.Millisecond}},
+	}
+	assert.Equal(t, context.DeadlineExceeded, p.Run(ctx))
+}
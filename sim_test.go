@@ -0,0 +1,219 @@
+package godaq
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Simulated device implementing the Transport interface
+type simDevice struct {
+	sync.Mutex
+	model    uint8
+	serial   uint32
+	adc      int16
+	inputs   map[uint8]int16 // ADC value per positive input (adc if missing)
+	pos      uint8
+	port     uint8
+	capture  [3]uint32 // Time returned by CAPTURE_GET per mode (µs)
+	requests []Message
+	fail     int   // Number of next requests that fail with a NAK
+	err      error // Error returned by Write (the port is gone)
+	resp     bytes.Buffer
+}
+
+func (s *simDevice) Write(b []byte) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.err != nil {
+		return 0, s.err
+	}
+	msg := Message{CommandNumber(b[2]), append([]byte(nil), b[4:]...)}
+	s.requests = append(s.requests, msg)
+	s.resp.Reset()
+
+	if s.fail > 0 {
+		s.fail--
+		s.resp.Write((&Message{Number: nak}).mustMarshal())
+		return len(b), nil
+	}
+	c, ok := LookupCommand(msg.Number)
+	if _, err := c.DecodeRequest(msg.Body); !ok || err != nil {
+		s.resp.Write((&Message{Number: nak}).mustMarshal())
+		return len(b), nil
+	}
+	// Echo the request unless the response is known
+	body := make([]byte, c.ResponseLen(msg.Body))
+	copy(body, msg.Body)
+	switch msg.Number {
+	case ID_CONFIG:
+		body, _ = c.Response.Encode(s.model, 1, s.serial)
+	case GET_CALIB:
+		body, _ = c.Response.Encode(msg.Body[0], 0, 0)
+	case AIN_CFG:
+		s.pos = msg.Body[0]
+		body, _ = c.Response.Encode(s.value(), msg.Body[0], msg.Body[1], msg.Body[2], msg.Body[3])
+	case AIN:
+		body, _ = c.Response.Encode(s.value())
+	case PIO:
+		if len(msg.Body) == 1 {
+			body[1] = (s.port >> (msg.Body[0] - 1)) & 1
+		} else {
+			s.port &^= 1 << (msg.Body[0] - 1)
+			s.port |= msg.Body[1] << (msg.Body[0] - 1)
+		}
+	case PORT:
+		if len(msg.Body) == 0 {
+			body[0] = s.port
+		} else {
+			s.port = msg.Body[0]
+		}
+	case CAPTURE_GET:
+		body, _ = c.Response.Encode(msg.Body[0], s.capture[msg.Body[0]%3])
+	case SPISW_TRANSFER:
+		// MISO reads the inverted MOSI bits
+		for i := range body {
+			body[i] = ^msg.Body[i]
+		}
+	}
+	s.resp.Write((&Message{msg.Number, body}).mustMarshal())
+	return len(b), nil
+}
+
+// Value of the selected ADC input
+func (s *simDevice) value() int16 {
+	if v, ok := s.inputs[s.pos]; ok {
+		return v
+	}
+	return s.adc
+}
+
+func (s *simDevice) Read(b []byte) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	return s.resp.Read(b)
+}
+
+func (s *simDevice) Close() error { return nil }
+func (s *simDevice) Flush() error { return nil }
+
+// Return the requests received with the given command number
+func (s *simDevice) sent(n CommandNumber) []Message {
+	s.Lock()
+	defer s.Unlock()
+	var list []Message
+	for _, m := range s.requests {
+		if m.Number == n {
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
+func (m *Message) mustMarshal() []byte {
+	b, _ := m.Marshal()
+	return b
+}
+
+func newSimDAQ(t *testing.T, opts ...Option) (*OpenDAQ, *simDevice) {
+	sim := &simDevice{model: ModelMId, serial: 42}
+	daq, err := New("sim", append([]Option{WithTransport(sim)}, opts...)...)
+	assert.Nil(t, err)
+	if daq != nil {
+		t.Cleanup(func() { daq.Close() })
+	}
+	return daq, sim
+}
+
+func TestNewSim(t *testing.T) {
+	daq, sim := newSimDAQ(t)
+	defer daq.Close()
+	model, version, serial, err := daq.GetInfo()
+	assert.Nil(t, err)
+	assert.EqualValues(t, ModelMId, model)
+	assert.EqualValues(t, 1, version)
+	assert.Equal(t, "0042", serial)
+	assert.Len(t, sim.sent(GET_CALIB), int(daq.NCalibRegs))
+}
+
+func TestCalibSources(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	assert.Empty(t, sim.sent(GET_CALIB))
+	assert.Equal(t, Calib{1, 0}, daq.GetCalib(true, false, false, 1, 0))
+
+	daq, sim = newSimDAQ(t, WithLazyCalib())
+	assert.Empty(t, sim.sent(GET_CALIB))
+	daq.GetCalib(false, false, false, 1, 0)
+	assert.Len(t, sim.sent(GET_CALIB), int(daq.NCalibRegs))
+
+	daq, sim = newSimDAQ(t, WithModel(ModelSId), WithReadyTimeout(0), WithIdentityCalib())
+	assert.Empty(t, sim.sent(ID_CONFIG))
+	assert.Equal(t, "OpenDAQ S", daq.Name)
+}
+
+func TestCalibFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "godaq")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "calib.json")
+	daq, _ := newSimDAQ(t)
+	daq.calib[0] = Calib{1.5, 0.25}
+	assert.Nil(t, daq.WriteCalibFile(path))
+
+	daq, sim := newSimDAQ(t, WithCalibFile(path))
+	assert.Empty(t, sim.sent(GET_CALIB))
+	assert.Equal(t, Calib{1.5, 0.25}, daq.GetCalib(true, false, false, 1, 0))
+
+	sim = &simDevice{model: ModelSId}
+	_, err = New("sim", WithTransport(sim), WithCalibFile(path))
+	assert.NotNil(t, err)
+
+	// Another unit of the same model
+	sim = &simDevice{model: ModelMId, serial: 43}
+	_, err = New("sim", WithTransport(sim), WithCalibFile(path))
+	assert.EqualError(t, err, "The calibration file belongs to another device (serial 0042)")
+}
+
+func TestRetries(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithRetries(2, 0))
+	sim.fail = 1
+	assert.Nil(t, daq.SetLED(1, RED))
+	sim.fail = 2
+	assert.Equal(t, ErrNakReceived, daq.SetLED(1, RED))
+}
+
+func TestSimReadAnalog(t *testing.T) {
+	daq, sim := newSimDAQ(t)
+	sim.adc = -16384
+	assert.Nil(t, daq.ConfigureADC(1, 0, 1, 10))
+	v, err := daq.ReadAnalog()
+	assert.Nil(t, err)
+	assert.InDelta(t, 2.048, v, 1e-4)
+	assert.Equal(t, []Message{{AIN_CFG, []byte{1, 0, 1, 10}}}, sim.sent(AIN_CFG))
+}
+
+func TestLazyCalibInTransaction(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithLazyCalib())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		daq.Do(func(tx *Tx) error {
+			time.Sleep(20 * time.Millisecond)
+			return tx.SetAnalog(1, 1)
+		})
+	}()
+	go daq.GetCalib(false, false, false, 1, 0)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock loading the calibration")
+	}
+	daq.GetCalib(false, false, false, 1, 0)
+	assert.Len(t, sim.sent(GET_CALIB), int(daq.NCalibRegs))
+}
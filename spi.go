@@ -0,0 +1,141 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"errors"
+)
+
+// Maximum number of bytes sent in a single transfer command
+const spiChunkLen = 64
+
+var (
+	ErrInvalidSPIMode  = errors.New("Invalid SPI mode")
+	ErrInvalidWordSize = errors.New("Invalid SPI word size")
+	ErrInvalidSPILen   = errors.New("Invalid SPI transfer length")
+)
+
+// SPI clock polarity (CPOL) and phase (CPHA): mode = CPOL<<1 | CPHA
+type SPIMode uint8
+
+const (
+	SPIMode0 SPIMode = iota
+	SPIMode1
+	SPIMode2
+	SPIMode3
+)
+
+// Full-duplex SPI connection, compatible with the connection interface used
+// by most Go device drivers
+type SPIConn interface {
+	Tx(w, r []byte) error
+}
+
+// Configuration of the software SPI master.
+// The pins are PIO numbers. A zero pin selects the default one
+// (SCK: 1, MOSI: 2, MISO: 3).
+type SPIConfig struct {
+	SCK, MOSI, MISO uint
+	Mode            SPIMode
+	WordBits        uint // 8 (default) or 16
+}
+
+// Bit-banged SPI master running on the PIO lines of the device
+type SPI struct {
+	daq *OpenDAQ
+	cfg SPIConfig
+}
+
+var _ SPIConn = (*SPI)(nil)
+
+// Configure the PIO lines as a SPI bus and return the SPI master
+func (daq *OpenDAQ) OpenSPI(cfg SPIConfig) (*SPI, error) {
+	if cfg.SCK == 0 {
+		cfg.SCK = 1
+	}
+	if cfg.MOSI == 0 {
+		cfg.MOSI = 2
+	}
+	if cfg.MISO == 0 {
+		cfg.MISO = 3
+	}
+	if cfg.WordBits == 0 {
+		cfg.WordBits = 8
+	}
+	for _, pin := range []uint{cfg.SCK, cfg.MOSI, cfg.MISO} {
+		if pin > daq.NPIOs {
+			return nil, ErrInvalidPIO
+		}
+	}
+	if cfg.SCK == cfg.MOSI || cfg.SCK == cfg.MISO || cfg.MOSI == cfg.MISO {
+		return nil, errors.New("SPI pins must be different")
+	}
+	if cfg.Mode > SPIMode3 {
+		return nil, ErrInvalidSPIMode
+	}
+	if cfg.WordBits != 8 && cfg.WordBits != 16 {
+		return nil, ErrInvalidWordSize
+	}
+
+	cpol, cpha := byte(cfg.Mode>>1), byte(cfg.Mode&1)
+	err := daq.Do(func(tx *Tx) error {
+		if _, err := tx.call(SPISW_CONFIG, cpol, cpha); err != nil {
+			return err
+		}
+		_, err := tx.call(SPISW_SETUP, cfg.WordBits/8, cfg.SCK, cfg.MOSI, cfg.MISO)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &SPI{daq, cfg}, nil
+}
+
+// Return the configuration of the SPI master
+func (s *SPI) Config() SPIConfig {
+	return s.cfg
+}
+
+// Write w while reading into r. r must be empty (write only) or have the
+// same length as w. If w is empty, zeros are written while reading r.
+// With 16-bit words the lengths must be even and the words are big-endian.
+// Long transfers are split in several commands, which are sent without
+// other commands in between.
+func (s *SPI) Tx(w, r []byte) error {
+	if len(w) == 0 {
+		w = make([]byte, len(r))
+	}
+	if len(r) != 0 && len(r) != len(w) {
+		return ErrInvalidSPILen
+	}
+	if s.cfg.WordBits == 16 && len(w)%2 != 0 {
+		return ErrInvalidSPILen
+	}
+	return s.daq.Do(func(tx *Tx) error {
+		for start := 0; start < len(w); start += spiChunkLen {
+			end := start + spiChunkLen
+			if end > len(w) {
+				end = len(w)
+			}
+			vals, err := tx.call(SPISW_TRANSFER, w[start:end])
+			if err != nil {
+				return err
+			}
+			if len(r) != 0 {
+				copy(r[start:end], vals.mustBytes("data"))
+			}
+		}
+		return nil
+	})
+}
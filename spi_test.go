@@ -0,0 +1,63 @@
+package godaq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenSPI(t *testing.T) {
+	daq, sim := newSimDAQ(t)
+	spi, err := daq.OpenSPI(SPIConfig{Mode: SPIMode2, WordBits: 16})
+	assert.Nil(t, err)
+	assert.Equal(t, SPIConfig{1, 2, 3, SPIMode2, 16}, spi.Config())
+	assert.Equal(t, []Message{{SPISW_CONFIG, []byte{1, 0}}}, sim.sent(SPISW_CONFIG))
+	assert.Equal(t, []Message{{SPISW_SETUP, []byte{2, 1, 2, 3}}}, sim.sent(SPISW_SETUP))
+
+	_, err = daq.OpenSPI(SPIConfig{Mode: 4})
+	assert.Equal(t, ErrInvalidSPIMode, err)
+	_, err = daq.OpenSPI(SPIConfig{WordBits: 12})
+	assert.Equal(t, ErrInvalidWordSize, err)
+	_, err = daq.OpenSPI(SPIConfig{SCK: 7})
+	assert.Equal(t, ErrInvalidPIO, err)
+	_, err = daq.OpenSPI(SPIConfig{SCK: 2})
+	assert.NotNil(t, err)
+	assert.Len(t, sim.sent(SPISW_CONFIG), 1)
+}
+
+func TestSPITx(t *testing.T) {
+	daq, sim := newSimDAQ(t)
+	spi, err := daq.OpenSPI(SPIConfig{})
+	assert.Nil(t, err)
+
+	w := make([]byte, 150)
+	for i := range w {
+		w[i] = byte(i)
+	}
+	r := make([]byte, len(w))
+	assert.Nil(t, spi.Tx(w, r))
+	sent := sim.sent(SPISW_TRANSFER)
+	if assert.Len(t, sent, 3) {
+		assert.Equal(t, w[:64], sent[0].Body)
+		assert.Equal(t, w[64:128], sent[1].Body)
+		assert.Equal(t, w[128:], sent[2].Body)
+	}
+	for i := range r {
+		assert.Equal(t, ^w[i], r[i])
+	}
+
+	// Read only: zeros are written
+	r = make([]byte, 2)
+	assert.Nil(t, spi.Tx(nil, r))
+	assert.Equal(t, []byte{0xff, 0xff}, r)
+
+	assert.Equal(t, ErrInvalidSPILen, spi.Tx(w, r))
+}
+
+func TestSPITx16(t *testing.T) {
+	daq, _ := newSimDAQ(t)
+	spi, err := daq.OpenSPI(SPIConfig{WordBits: 16})
+	assert.Nil(t, err)
+	assert.Equal(t, ErrInvalidSPILen, spi.Tx([]byte{1, 2, 3}, nil))
+	assert.Nil(t, spi.Tx([]byte{1, 2}, nil))
+}
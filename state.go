@@ -0,0 +1,195 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+// ADC input configuration
+type ADCConfig struct {
+	PosInput, NegInput uint
+	GainId             uint
+	NSamples           uint8
+}
+
+// Last value written to an analog output
+type AnalogState struct {
+	Known bool
+	Raw   int
+	Volts float32
+}
+
+// Last direction and value written to a PIO
+type PIOState struct {
+	DirKnown   bool
+	Output     bool
+	ValueKnown bool
+	Value      bool
+}
+
+// Last color written to a LED
+type LEDState struct {
+	Known bool
+	Color Color
+}
+
+// Copy of the configuration written to the device.
+// The slices are indexed by output, PIO or LED number minus one.
+type State struct {
+	Analog   []AnalogState
+	PIO      []PIOState
+	LED      []LEDState
+	ADC      ADCConfig
+	ADCKnown bool
+}
+
+// Return the PIO values as a port value and the mask of the known ones
+func (s *State) Port() (value, known uint8) {
+	for i, p := range s.PIO {
+		if p.ValueKnown {
+			known |= 1 << uint(i)
+			if p.Value {
+				value |= 1 << uint(i)
+			}
+		}
+	}
+	return
+}
+
+// Return the PIO directions as a port value and the mask of the known ones
+func (s *State) PortDir() (dir, known uint8) {
+	for i, p := range s.PIO {
+		if p.DirKnown {
+			known |= 1 << uint(i)
+			if p.Output {
+				dir |= 1 << uint(i)
+			}
+		}
+	}
+	return
+}
+
+// Return a copy of the last configuration written to the device
+func (daq *OpenDAQ) Snapshot() State {
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	s := State{
+		Analog:   make([]AnalogState, len(daq.outputs)),
+		PIO:      append([]PIOState(nil), daq.pios...),
+		LED:      append([]LEDState(nil), daq.leds...),
+		ADC:      daq.adc,
+		ADCKnown: daq.adcKnown,
+	}
+	for i := range daq.outputs {
+		s.Analog[i] = daq.outputs[i].AnalogState
+	}
+	return s
+}
+
+// Write the known values of a snapshot to the device, for instance after a
+// reconnection or on a replacement unit. The analog outputs are set in volts,
+// so the calibration of the device in use is applied.
+// Slew limits and interlocks are bypassed, but the output and PIO limits are
+// checked.
+// All the values are written even if some of them fail; the first error is
+// returned.
+func (daq *OpenDAQ) Restore(s State) error {
+	var firstErr error
+	check := func(err error) {
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	if s.ADCKnown {
+		check(daq.ConfigureADC(s.ADC.PosInput, s.ADC.NegInput, s.ADC.GainId, s.ADC.NSamples))
+	}
+	for i, p := range s.PIO {
+		if !p.ValueKnown {
+			continue
+		}
+		n := uint(i + 1)
+		if n > daq.NPIOs {
+			check(ErrInvalidPIO)
+		} else if err := daq.checkPIO(n, p.Value); err != nil {
+			check(err)
+		} else {
+			check(daq.writePIO(n, p.Value))
+		}
+	}
+	for i, p := range s.PIO {
+		if p.DirKnown {
+			check(daq.SetPIODir(uint(i+1), p.Output))
+		}
+	}
+	for i, a := range s.Analog {
+		if a.Known {
+			check(daq.setAnalog(uint(i+1), a.Volts))
+		}
+	}
+	for i, l := range s.LED {
+		if l.Known {
+			check(daq.SetLED(uint(i+1), l.Color))
+		}
+	}
+	return firstErr
+}
+
+// Allocate the shadow state for the hardware features of the device
+func (daq *OpenDAQ) initState() {
+	daq.outputs = make([]outputState, daq.NOutputs+daq.NHiddenOutputs)
+	daq.pios = make([]PIOState, daq.NPIOs)
+	daq.leds = make([]LEDState, daq.NLeds)
+	daq.pioLimits = make([]PIOLimit, daq.NPIOs)
+}
+
+func (daq *OpenDAQ) storePIO(n uint, value bool) {
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	if n >= 1 && n <= uint(len(daq.pios)) {
+		daq.pios[n-1].ValueKnown = true
+		daq.pios[n-1].Value = value
+	}
+}
+
+func (daq *OpenDAQ) storePIODir(n uint, output bool) {
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	if n >= 1 && n <= uint(len(daq.pios)) {
+		daq.pios[n-1].DirKnown = true
+		daq.pios[n-1].Output = output
+	}
+}
+
+func (daq *OpenDAQ) storePort(value uint8) {
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	for i := range daq.pios {
+		daq.pios[i].ValueKnown = true
+		daq.pios[i].Value = value&(1<<uint(i)) != 0
+	}
+}
+
+func (daq *OpenDAQ) storePortDir(dir uint8) {
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	for i := range daq.pios {
+		daq.pios[i].DirKnown = true
+		daq.pios[i].Output = dir&(1<<uint(i)) != 0
+	}
+}
+
+func (daq *OpenDAQ) storeLED(n uint, c Color) {
+	daq.stateLock.Lock()
+	defer daq.stateLock.Unlock()
+	if n >= 1 && n <= uint(len(daq.leds)) {
+		daq.leds[n-1] = LEDState{true, c}
+	}
+}
@@ -0,0 +1,67 @@
+package godaq
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot(t *testing.T) {
+	daq := newTestDAQ(t)
+	daq.storePortDir(0x0f)
+	daq.storePort(0x05)
+	daq.storePIO(2, true)
+	daq.storeOutput(1, 16384, 2.048)
+	daq.storeLED(1, GREEN)
+
+	s := daq.Snapshot()
+	assert.Equal(t, []AnalogState{{true, 16384, 2.048}}, s.Analog)
+	assert.Equal(t, []LEDState{{true, GREEN}}, s.LED)
+	assert.False(t, s.ADCKnown)
+
+	value, known := s.Port()
+	assert.EqualValues(t, 0x07, value)
+	assert.EqualValues(t, 0x3f, known)
+	dir, known := s.PortDir()
+	assert.EqualValues(t, 0x0f, dir)
+	assert.EqualValues(t, 0x3f, known)
+
+	// the snapshot is a copy
+	daq.storeLED(1, RED)
+	assert.Equal(t, GREEN, s.LED[0].Color)
+
+	// restoring an empty state doesn't send any command
+	assert.Nil(t, daq.Restore(State{}))
+}
+
+func TestRestore(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	s := State{
+		Analog: []AnalogState{{Known: true, Volts: 1}},
+		PIO: []PIOState{
+			{DirKnown: true, Output: true, ValueKnown: true, Value: true},
+			{},
+			{DirKnown: true, Output: false},
+		},
+		LED: []LEDState{{true, RED}},
+	}
+	assert.Nil(t, daq.Restore(s))
+	assert.Equal(t, []Message{{PIO, []byte{1, 1}}}, sim.sent(PIO))
+	assert.Equal(t, []Message{{PIO_DIR, []byte{1, 1}}, {PIO_DIR, []byte{3, 0}}}, sim.sent(PIO_DIR))
+	assert.Equal(t, []Message{{SET_DAC, []byte{0x1f, 0x40, 1}}}, sim.sent(SET_DAC))
+	assert.Equal(t, []Message{{LED_W, []byte{byte(RED), 1}}}, sim.sent(LED_W))
+	assert.Equal(t, s.Analog[0].Volts, daq.Snapshot().Analog[0].Volts)
+	assert.Equal(t, s.PIO, daq.Snapshot().PIO[:3])
+}
+
+func TestRestorePIOChecks(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	assert.Nil(t, daq.SetPIOLimit(2, PIOLowOnly))
+	pios := make([]PIOState, 8)
+	pios[1] = PIOState{ValueKnown: true, Value: true}
+	pios[2] = PIOState{ValueKnown: true, Value: true}
+	pios[7] = PIOState{ValueKnown: true, Value: true}
+	assert.Equal(t, &LimitError{"PIO", 2, 1, 0, 0}, daq.Restore(State{PIO: pios}))
+	// Only the valid PIO is written
+	assert.Equal(t, []Message{{PIO, []byte{3, 1}}}, sim.sent(PIO))
+}
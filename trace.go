@@ -0,0 +1,112 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Return the name of the command, or CMD(n) if it's unknown
+func (n CommandNumber) String() string {
+	if c, ok := commands[n]; ok {
+		return c.Name
+	}
+	if n == nak {
+		return "NAK"
+	}
+	return fmt.Sprintf("CMD(%d)", uint8(n))
+}
+
+// Check if the command is known by this package (see LookupCommand)
+func (n CommandNumber) Known() bool {
+	_, ok := commands[n]
+	return ok
+}
+
+// Check if the number is the one of a NAK response
+func (n CommandNumber) IsNAK() bool {
+	return n == nak
+}
+
+type TraceDirection uint8
+
+const (
+	TraceRequest  TraceDirection = iota // Frame sent to the device
+	TraceResponse                       // Frame received from the device
+)
+
+func (d TraceDirection) String() string {
+	if d == TraceRequest {
+		return "request"
+	}
+	return "response"
+}
+
+// Frame sent or received
+type TraceEvent struct {
+	Time      time.Time
+	Direction TraceDirection
+	Command   CommandNumber // Number in the frame (NAK for a rejected command)
+	Body      []byte
+	Frame     []byte // Whole frame, as many bytes as were received
+	Attempt   int    // Attempt number of the command, starting at 1
+	Err       error  // I/O or decoding error
+}
+
+// Tracer gets every frame exchanged with the device. It's called from the
+// I/O goroutine, so it should return quickly. The event must not be retained.
+type Tracer interface {
+	Trace(ev *TraceEvent)
+}
+
+// Adapter to use a function as a Tracer
+type TracerFunc func(ev *TraceEvent)
+
+func (f TracerFunc) Trace(ev *TraceEvent) {
+	f(ev)
+}
+
+type hexTracer struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Return a tracer writing a line per frame with the time, the command name,
+// the attempt and a hex dump of the frame (header | body), such as:
+//
+//	15:04:05.000000 > LED_W #1 00 17 12 02 | 02 01
+func NewHexTracer(w io.Writer) Tracer {
+	return &hexTracer{w: w}
+}
+
+func (t *hexTracer) Trace(ev *TraceEvent) {
+	dir := ">"
+	if ev.Direction == TraceResponse {
+		dir = "<"
+	}
+	dump := fmt.Sprintf("% x", ev.Frame)
+	if len(ev.Frame) > 4 {
+		dump = fmt.Sprintf("% x | % x", ev.Frame[:4], ev.Frame[4:])
+	}
+	line := fmt.Sprintf("%s %s %s #%d %s", ev.Time.Format("15:04:05.000000"), dir, ev.Command, ev.Attempt, dump)
+	if ev.Err != nil {
+		line += " error: " + ev.Err.Error()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintln(t.w, line)
+}
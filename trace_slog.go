@@ -0,0 +1,50 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.21
+// +build go1.21
+
+package godaq
+
+import (
+	"context"
+	"encoding/hex"
+	"log/slog"
+)
+
+type slogTracer struct {
+	l *slog.Logger
+}
+
+// Return a tracer writing structured logs. Frames are logged at debug level
+// and failed exchanges at warning level.
+func NewSlogTracer(l *slog.Logger) Tracer {
+	return slogTracer{l}
+}
+
+func (t slogTracer) Trace(ev *TraceEvent) {
+	level := slog.LevelDebug
+	attrs := []slog.Attr{
+		slog.Time("time", ev.Time),
+		slog.String("dir", ev.Direction.String()),
+		slog.String("cmd", ev.Command.String()),
+		slog.Int("number", int(ev.Command)),
+		slog.Int("attempt", ev.Attempt),
+		slog.String("body", hex.EncodeToString(ev.Body)),
+	}
+	if ev.Err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("error", ev.Err.Error()))
+	}
+	t.l.LogAttrs(context.Background(), level, "opendaq frame", attrs...)
+}
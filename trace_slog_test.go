@@ -0,0 +1,27 @@
+//go:build go1.21
+// +build go1.21
+
+package godaq
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlogTracer(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	daq, _ := newSimDAQ(t, WithIdentityCalib())
+	daq.cfg.tracer = NewSlogTracer(l)
+	assert.Nil(t, daq.SetLED(1, RED))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], "level=DEBUG")
+	assert.Contains(t, lines[0], "dir=request cmd=LED_W number=18 attempt=1 body=0201")
+	assert.Contains(t, lines[1], "dir=response cmd=LED_W")
+}
@@ -0,0 +1,68 @@
+package godaq
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type traceLog struct {
+	sync.Mutex
+	events []TraceEvent
+}
+
+func (l *traceLog) Trace(ev *TraceEvent) {
+	l.Lock()
+	defer l.Unlock()
+	l.events = append(l.events, *ev)
+}
+
+func TestTracer(t *testing.T) {
+	var log traceLog
+	daq, sim := newSimDAQ(t, WithIdentityCalib(), WithRetries(2, 0), WithTracer(&log))
+	assert.Len(t, log.events, 2) // handshake
+
+	log.events = nil
+	sim.fail = 1
+	assert.Nil(t, daq.SetLED(1, RED))
+	assert.Len(t, log.events, 4)
+
+	ev := log.events[0]
+	assert.Equal(t, TraceRequest, ev.Direction)
+	assert.Equal(t, CommandNumber(LED_W), ev.Command)
+	assert.Equal(t, []byte{2, 1}, ev.Body)
+	assert.Equal(t, 1, ev.Attempt)
+
+	ev = log.events[1]
+	assert.Equal(t, TraceResponse, ev.Direction)
+	assert.Equal(t, CommandNumber(nak), ev.Command)
+	assert.Equal(t, ErrNakReceived, ev.Err)
+
+	assert.Equal(t, 2, log.events[2].Attempt)
+	ev = log.events[3]
+	assert.Equal(t, CommandNumber(LED_W), ev.Command)
+	assert.Equal(t, []byte{2, 1}, ev.Body)
+	assert.Nil(t, ev.Err)
+}
+
+func TestCommandString(t *testing.T) {
+	assert.Equal(t, "AIN_CFG", CommandNumber(AIN_CFG).String())
+	assert.Equal(t, "NAK", CommandNumber(nak).String())
+	assert.Equal(t, "CMD(99)", CommandNumber(99).String())
+}
+
+func TestHexTracer(t *testing.T) {
+	var buf bytes.Buffer
+	daq, sim := newSimDAQ(t, WithIdentityCalib(), WithRetries(1, 0))
+	daq.cfg.tracer = NewHexTracer(&buf)
+	sim.fail = 1
+	daq.SetLED(1, RED)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.True(t, strings.HasSuffix(lines[0], "> LED_W #1 00 17 12 02 | 02 01"), lines[0])
+	assert.True(t, strings.HasSuffix(lines[1], "< NAK #1 00 a0 a0 00 error: NAK response received"), lines[1])
+}
@@ -0,0 +1,38 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+// Transaction holding the device for several commands. It has the same
+// methods as OpenDAQ, which send their commands directly instead of queueing
+// them.
+// A transaction must not be used after the function passed to Do returns.
+// Ramps can't run in a transaction (see RampAnalog).
+type Tx struct {
+	*OpenDAQ
+}
+
+// Run f with the device held, so that the commands sent by other goroutines
+// can't be interleaved with the ones of f. Returns the error of f.
+// Transactions are queued with PriorityOutput.
+// Calling Do inside a transaction runs f in the same transaction.
+func (daq *OpenDAQ) Do(f func(tx *Tx) error) error {
+	return daq.Go(f).Wait()
+}
+
+// Queue a transaction and return its future without waiting for it
+func (daq *OpenDAQ) Go(f func(tx *Tx) error) *Future {
+	return daq.enqueue(PriorityOutput, func(d *OpenDAQ) error {
+		return f(&Tx{d})
+	})
+}
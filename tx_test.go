@@ -0,0 +1,61 @@
+package godaq
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDo(t *testing.T) {
+	daq, sim := newSimDAQ(t)
+	sim.inputs = map[uint8]int16{1: -16384}
+
+	var v float32
+	err := daq.Do(func(tx *Tx) error {
+		if err := tx.SetPIODir(1, true); err != nil {
+			return err
+		}
+		if err := tx.SetPIO(1, true); err != nil {
+			return err
+		}
+		// Nested transactions share the lock
+		return tx.Do(func(tx *Tx) (err error) {
+			v, err = tx.ReadChannel(1, 0, 1, 10)
+			return
+		})
+	})
+	assert.Nil(t, err)
+	assert.InDelta(t, 2.048, v, 1e-4)
+	assert.Equal(t, uint8(1), sim.port&1)
+
+	errAbort := errors.New("Abort")
+	assert.Equal(t, errAbort, daq.Do(func(tx *Tx) error { return errAbort }))
+}
+
+func TestDoExclusive(t *testing.T) {
+	daq, sim := newSimDAQ(t)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for i := 0; i < 2; i++ {
+		go func(color Color) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				daq.Do(func(tx *Tx) error {
+					tx.SetLED(1, color)
+					time.Sleep(time.Microsecond)
+					return tx.SetLED(1, color)
+				})
+			}
+		}(Color(i + 1))
+	}
+	wg.Wait()
+
+	leds := sim.sent(LED_W)
+	assert.Len(t, leds, 80)
+	for i := 0; i < len(leds); i += 2 {
+		assert.Equal(t, leds[i], leds[i+1])
+	}
+}
@@ -15,17 +15,68 @@ package godaq
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/tarm/serial"
+)
+
+// Used to replace the probe in the tests
+var probeFunc = ProbePort
+
+// Time given to a device to answer the discovery handshake. It includes the
+// boot time of the boards that reset when the port is opened.
+const probeTimeout = 2500 * time.Millisecond
+
+// Roots of the device and sysfs trees (replaced by the tests)
+var (
+	devRoot   = "/dev"
+	sysfsRoot = "/sys"
 )
 
+// USB-serial adapters used by OpenDAQ devices (vendor ID, product ID)
+var likelyAdapters = [][2]uint16{
+	{0x0403, 0x6001}, // FTDI FT232R
+	{0x0403, 0x6015}, // FTDI FT231X
+	{0x10c4, 0xea60}, // Silicon Labs CP210x
+	{0x1a86, 0x7523}, // WCH CH340
+	{0x067b, 0x2303}, // Prolific PL2303
+}
+
+// Devices opened by this process, indexed by port. They are not probed again.
+var (
+	openPortsLock sync.Mutex
+	openPorts     = make(map[string]DevicePort)
+)
+
+var ErrDeviceNotFound = errors.New("No matching device found")
+
+// Error returned when more than one device matches the search
+type MultipleDevicesError struct {
+	Devices []DevicePort
+}
+
+func (e *MultipleDevicesError) Error() string {
+	var list []string
+	for _, d := range e.Devices {
+		list = append(list, fmt.Sprintf("%s (serial %s)", d.Port, d.Serial))
+	}
+	return fmt.Sprintf("%d matching devices found: %s", len(e.Devices), strings.Join(list, ", "))
+}
+
 // List all available USB-serial ports (Linux only)
 func ListPorts() ([]string, error) {
 	if runtime.GOOS != "linux" {
 		return nil, errors.New("Not supported OS")
 	}
-	files, err := ioutil.ReadDir("/dev")
+	files, err := ioutil.ReadDir(devRoot)
 	if err != nil {
 		return nil, err
 	}
@@ -33,30 +84,242 @@ func ListPorts() ([]string, error) {
 	for _, file := range files {
 		n := file.Name()
 		if strings.HasPrefix(n, "ttyUSB") || strings.HasPrefix(n, "ttyACM") {
-			list = append(list, "/dev/"+n)
+			list = append(list, filepath.Join(devRoot, n))
 		}
 	}
 	return list, nil
 }
 
+// USB identity of a serial port
+type USBInfo struct {
+	VendorID, ProductID uint16
+	Manufacturer        string
+	Product             string
+	Serial              string
+	ByID                string // Link in /dev/serial/by-id (if any)
+}
+
+// Return true if the port uses an adapter found in OpenDAQ devices
+func (u *USBInfo) LikelyOpenDAQ() bool {
+	if strings.Contains(strings.ToLower(u.Manufacturer+u.Product), "opendaq") {
+		return true
+	}
+	for _, id := range likelyAdapters {
+		if u.VendorID == id[0] && u.ProductID == id[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// Read the USB identity of a serial port from sysfs (Linux only)
+func ReadUSBInfo(port string) (info USBInfo, err error) {
+	name := filepath.Base(port)
+	dev, err := filepath.EvalSymlinks(filepath.Join(sysfsRoot, "class", "tty", name, "device"))
+	if err != nil {
+		return info, err
+	}
+	// The USB device is an ancestor of the tty device (usb-serial adapters)
+	// or of its interface (CDC ACM)
+	for ; ; dev = filepath.Dir(dev) {
+		if _, err := os.Stat(filepath.Join(dev, "idVendor")); err == nil {
+			break
+		}
+		if dev == filepath.Dir(dev) {
+			return info, errors.New("Not a USB device")
+		}
+	}
+	readAttr := func(attr string) string {
+		b, _ := ioutil.ReadFile(filepath.Join(dev, attr))
+		return strings.TrimSpace(string(b))
+	}
+	vid, err := strconv.ParseUint(readAttr("idVendor"), 16, 16)
+	if err != nil {
+		return info, err
+	}
+	pid, err := strconv.ParseUint(readAttr("idProduct"), 16, 16)
+	if err != nil {
+		return info, err
+	}
+	info = USBInfo{
+		VendorID:     uint16(vid),
+		ProductID:    uint16(pid),
+		Manufacturer: readAttr("manufacturer"),
+		Product:      readAttr("product"),
+		Serial:       readAttr("serial"),
+		ByID:         serialLink(port),
+	}
+	return info, nil
+}
+
+// Return the /dev/serial/by-id link pointing to a port
+func serialLink(port string) string {
+	dir := filepath.Join(devRoot, "serial", "by-id")
+	links, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	target, err := filepath.EvalSymlinks(port)
+	if err != nil {
+		return ""
+	}
+	for _, l := range links {
+		link := filepath.Join(dir, l.Name())
+		if t, err := filepath.EvalSymlinks(link); err == nil && t == target {
+			return link
+		}
+	}
+	return ""
+}
+
 type DevicePort struct {
-	Model uint8
-	Port  string
+	Model   uint8
+	Port    string
+	Version uint8
+	Serial  string
+	USB     USBInfo
 }
 
+func registerPort(dp DevicePort) {
+	openPortsLock.Lock()
+	defer openPortsLock.Unlock()
+	openPorts[dp.Port] = dp
+}
+
+func unregisterPort(port string) {
+	openPortsLock.Lock()
+	defer openPortsLock.Unlock()
+	delete(openPorts, port)
+}
+
+func lookupPort(port string) (DevicePort, bool) {
+	openPortsLock.Lock()
+	defer openPortsLock.Unlock()
+	dp, ok := openPorts[port]
+	return dp, ok
+}
+
+// Open a port and ask for the device identity (ID_CONFIG) until it answers
+// or the timeout expires. The calibration is not read.
+// Ports already opened by this process are not touched, and ports locked by
+// other processes return a *BusyError.
+func ProbePort(port string, timeout time.Duration) (DevicePort, error) {
+	usb, _ := ReadUSBInfo(port)
+	if dp, ok := lookupPort(port); ok {
+		dp.USB = usb
+		return dp, nil
+	}
+	dp := DevicePort{Port: port, USB: usb}
+
+	ser, lock, err := openLocked(port, func() (Transport, error) {
+		ser, err := serial.OpenPort(&serial.Config{Name: port, Baud: 115200,
+			ReadTimeout: 100 * time.Millisecond})
+		if err != nil {
+			return nil, err
+		}
+		return ser, nil
+	})
+	if err != nil {
+		return dp, err
+	}
+	defer lock.unlock()
+	defer ser.Close()
+
+	id, err := handshake(ser, timeout, nil)
+	dp.Model, dp.Version, dp.Serial = id.Model, id.Version, id.Serial
+	return dp, err
+}
+
+// Find the OpenDAQ devices connected to the USB-serial ports (Linux only).
+// Ports with a known USB identity that doesn't match an OpenDAQ adapter are
+// skipped and the rest are probed in parallel.
 func ListDevicePorts() ([]DevicePort, error) {
+	list, _, err := probeDevicePorts()
+	return list, err
+}
+
+// Probe the candidate ports and return the devices found and the errors of
+// the ports that couldn't be probed
+func probeDevicePorts() ([]DevicePort, []error, error) {
 	ports, err := ListPorts()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	var list []DevicePort
+	var candidates []string
 	for _, port := range ports {
-		if dev, err := New(port); err == nil {
-			if model, _, _, err := dev.GetInfo(); err == nil {
-				list = append(list, DevicePort{model, port})
+		if usb, err := ReadUSBInfo(port); err == nil && !usb.LikelyOpenDAQ() {
+			continue
+		}
+		candidates = append(candidates, port)
+	}
+
+	found := make([]DevicePort, len(candidates))
+	errs := make([]error, len(candidates))
+	var wg sync.WaitGroup
+	for i, port := range candidates {
+		wg.Add(1)
+		go func(i int, port string) {
+			defer wg.Done()
+			found[i], errs[i] = probeFunc(port, probeTimeout)
+		}(i, port)
+	}
+	wg.Wait()
+
+	var list []DevicePort
+	var failed []error
+	for i := range found {
+		if errs[i] == nil {
+			list = append(list, found[i])
+		} else {
+			failed = append(failed, errs[i])
+		}
+	}
+	return list, failed, nil
+}
+
+// Open the device with the given serial number, as returned by GetInfo.
+// If it isn't found and a port is locked by another process, a *BusyError
+// is returned.
+func OpenBySerial(serial string, opts ...Option) (*OpenDAQ, error) {
+	return openMatching(func(dp *DevicePort) bool {
+		return dp.Serial == serial
+	}, opts)
+}
+
+// Open the only connected device of the given model (ModelMId, ModelSId...).
+// If several devices of the same model are connected, a
+// *MultipleDevicesError is returned and OpenBySerial must be used instead.
+func OpenByModel(model uint8, opts ...Option) (*OpenDAQ, error) {
+	return openMatching(func(dp *DevicePort) bool {
+		return dp.Model == model
+	}, opts)
+}
+
+// Open the only device accepted by match. If none matches but some port is
+// locked by another process, its *BusyError is returned instead of
+// ErrDeviceNotFound, as the device may be the one in use.
+func openMatching(match func(dp *DevicePort) bool, opts []Option) (*OpenDAQ, error) {
+	devices, failed, err := probeDevicePorts()
+	if err != nil {
+		return nil, err
+	}
+	var found []DevicePort
+	for i := range devices {
+		if match(&devices[i]) {
+			found = append(found, devices[i])
+		}
+	}
+	switch len(found) {
+	case 0:
+		for _, err := range failed {
+			var busy *BusyError
+			if errors.As(err, &busy) {
+				return nil, busy
 			}
-			dev.Close()
 		}
+		return nil, ErrDeviceNotFound
+	case 1:
+		return New(found[0].Port, opts...)
 	}
-	return list, nil
+	return nil, &MultipleDevicesError{found}
 }
@@ -0,0 +1,158 @@
+package godaq
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Build a fake /dev and /sys tree with a FTDI adapter at ttyUSB0 and a
+// CDC ACM device at ttyACM0
+func fakeDeviceTree(t *testing.T) (cleanup func()) {
+	root, err := ioutil.TempDir("", "godaq")
+	assert.Nil(t, err)
+	oldDev, oldSys := devRoot, sysfsRoot
+	devRoot = filepath.Join(root, "dev")
+	sysfsRoot = filepath.Join(root, "sys")
+
+	mkdir := func(p string) { assert.Nil(t, os.MkdirAll(p, 0755)) }
+	write := func(p, s string) { assert.Nil(t, ioutil.WriteFile(p, []byte(s), 0644)) }
+
+	mkdir(filepath.Join(devRoot, "serial", "by-id"))
+	write(filepath.Join(devRoot, "ttyUSB0"), "")
+	write(filepath.Join(devRoot, "ttyACM0"), "")
+	write(filepath.Join(devRoot, "ttyS0"), "")
+	os.Symlink("../../ttyUSB0", filepath.Join(devRoot, "serial", "by-id", "usb-FTDI_FT232R_A1B2-if00-port0"))
+
+	usb1 := filepath.Join(sysfsRoot, "devices", "usb1", "1-1")
+	mkdir(filepath.Join(usb1, "1-1:1.0", "ttyUSB0"))
+	write(filepath.Join(usb1, "idVendor"), "0403\n")
+	write(filepath.Join(usb1, "idProduct"), "6001\n")
+	write(filepath.Join(usb1, "manufacturer"), "FTDI\n")
+	write(filepath.Join(usb1, "product"), "FT232R USB UART\n")
+	write(filepath.Join(usb1, "serial"), "A1B2\n")
+
+	usb2 := filepath.Join(sysfsRoot, "devices", "usb1", "1-2")
+	mkdir(filepath.Join(usb2, "1-2:1.0"))
+	write(filepath.Join(usb2, "idVendor"), "2341\n")
+	write(filepath.Join(usb2, "idProduct"), "0043\n")
+
+	mkdir(filepath.Join(sysfsRoot, "class", "tty", "ttyUSB0"))
+	mkdir(filepath.Join(sysfsRoot, "class", "tty", "ttyACM0"))
+	os.Symlink(filepath.Join(usb1, "1-1:1.0", "ttyUSB0"), filepath.Join(sysfsRoot, "class", "tty", "ttyUSB0", "device"))
+	os.Symlink(filepath.Join(usb2, "1-2:1.0"), filepath.Join(sysfsRoot, "class", "tty", "ttyACM0", "device"))
+
+	return func() {
+		devRoot, sysfsRoot = oldDev, oldSys
+		os.RemoveAll(root)
+	}
+}
+
+func TestListPorts(t *testing.T) {
+	defer fakeDeviceTree(t)()
+	ports, err := ListPorts()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{filepath.Join(devRoot, "ttyACM0"), filepath.Join(devRoot, "ttyUSB0")}, ports)
+}
+
+func TestReadUSBInfo(t *testing.T) {
+	defer fakeDeviceTree(t)()
+	info, err := ReadUSBInfo(filepath.Join(devRoot, "ttyUSB0"))
+	assert.Nil(t, err)
+	assert.Equal(t, USBInfo{
+		VendorID:     0x0403,
+		ProductID:    0x6001,
+		Manufacturer: "FTDI",
+		Product:      "FT232R USB UART",
+		Serial:       "A1B2",
+		ByID:         filepath.Join(devRoot, "serial", "by-id", "usb-FTDI_FT232R_A1B2-if00-port0"),
+	}, info)
+	assert.True(t, info.LikelyOpenDAQ())
+
+	info, err = ReadUSBInfo(filepath.Join(devRoot, "ttyACM0"))
+	assert.Nil(t, err)
+	assert.EqualValues(t, 0x2341, info.VendorID)
+	assert.False(t, info.LikelyOpenDAQ())
+
+	_, err = ReadUSBInfo(filepath.Join(devRoot, "ttyS0"))
+	assert.NotNil(t, err)
+}
+
+func TestMultipleDevicesError(t *testing.T) {
+	err := &MultipleDevicesError{[]DevicePort{{Port: "/dev/ttyUSB0", Serial: "0001"},
+		{Port: "/dev/ttyUSB1", Serial: "0002"}}}
+	assert.EqualError(t, err, "2 matching devices found: /dev/ttyUSB0 (serial 0001), /dev/ttyUSB1 (serial 0002)")
+}
+
+// Replace the probe with one answering from a table of ports for the
+// duration of a test. Ports missing from the table don't answer.
+func stubProbe(t *testing.T, results map[string]DevicePort, errs map[string]error) {
+	probeFunc = func(port string, timeout time.Duration) (DevicePort, error) {
+		if err, ok := errs[port]; ok {
+			return DevicePort{Port: port}, err
+		}
+		if dp, ok := results[port]; ok {
+			dp.Port = port
+			return dp, nil
+		}
+		return DevicePort{Port: port}, errors.New("No answer")
+	}
+	t.Cleanup(func() { probeFunc = ProbePort })
+}
+
+func TestOpenMatching(t *testing.T) {
+	defer fakeDeviceTree(t)()
+	// A port without USB information is probed too
+	usb0, usb1 := filepath.Join(devRoot, "ttyUSB0"), filepath.Join(devRoot, "ttyUSB1")
+	assert.Nil(t, ioutil.WriteFile(usb1, nil, 0644))
+	stubProbe(t, map[string]DevicePort{usb0: {Model: ModelMId, Serial: "0042"}}, nil)
+	opts := []Option{WithTransport(&simDevice{model: ModelMId, serial: 42}), WithIdentityCalib()}
+
+	daq, err := OpenBySerial("0042", opts...)
+	if assert.Nil(t, err) {
+		assert.Equal(t, usb0, daq.info.Port)
+		daq.Close()
+	}
+	daq, err = OpenByModel(ModelMId, opts...)
+	if assert.Nil(t, err) {
+		daq.Close()
+	}
+
+	_, err = OpenBySerial("0043", opts...)
+	assert.Equal(t, ErrDeviceNotFound, err)
+	_, err = OpenByModel(ModelSId, opts...)
+	assert.Equal(t, ErrDeviceNotFound, err)
+
+	stubProbe(t, map[string]DevicePort{usb0: {Model: ModelMId, Serial: "0042"},
+		usb1: {Model: ModelMId, Serial: "0043"}}, nil)
+	_, err = OpenByModel(ModelMId, opts...)
+	assert.IsType(t, &MultipleDevicesError{}, err)
+}
+
+func TestOpenMatchingBusy(t *testing.T) {
+	defer fakeDeviceTree(t)()
+	usb0, usb1 := filepath.Join(devRoot, "ttyUSB0"), filepath.Join(devRoot, "ttyUSB1")
+	assert.Nil(t, ioutil.WriteFile(usb1, nil, 0644))
+	busy := &BusyError{Port: usb1, PID: 1234}
+	stubProbe(t, map[string]DevicePort{usb0: {Model: ModelMId, Serial: "0042"}},
+		map[string]error{usb1: busy})
+	opts := []Option{WithTransport(&simDevice{model: ModelMId, serial: 42}), WithIdentityCalib()}
+
+	// The busy port may hold the device
+	_, err := OpenBySerial("0043", opts...)
+	assert.Equal(t, busy, err)
+
+	daq, err := OpenBySerial("0042", opts...)
+	if assert.Nil(t, err) {
+		daq.Close()
+	}
+
+	list, err := ListDevicePorts()
+	assert.Nil(t, err)
+	assert.Len(t, list, 1)
+}
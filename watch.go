@@ -0,0 +1,169 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"context"
+	"time"
+)
+
+// Kind of hot-plug event
+type DeviceEventKind uint8
+
+const (
+	DeviceAttached DeviceEventKind = iota
+	DeviceDetached
+)
+
+// Hot-plug event. Device contains the port, the USB identity and the
+// identity returned by the device (model, version and serial number).
+type DeviceEvent struct {
+	Kind   DeviceEventKind
+	Device DevicePort
+}
+
+// Polling interval used when the one given to WatchDevices isn't positive
+const defaultWatchInterval = time.Second
+
+// Longest time between the probes of a port that doesn't answer
+const maxProbeRetry = 30 * time.Second
+
+// Report the OpenDAQ devices attached and detached, polling the serial ports
+// every interval (Linux only; 1 s if interval isn't positive). The devices
+// connected when the watch starts are reported as attached. New ports are
+// probed before reporting them. The ports that fail the probe, for instance
+// because they are busy or still booting, are probed again later, doubling
+// the delay each time up to 30 s. The channel is closed when ctx is
+// cancelled.
+func WatchDevices(ctx context.Context, interval time.Duration) <-chan DeviceEvent {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	events := make(chan DeviceEvent, 16)
+	go watchDevices(ctx, interval, probeFunc, events)
+	return events
+}
+
+func watchDevices(ctx context.Context, interval time.Duration,
+	probePort func(string, time.Duration) (DevicePort, error), events chan<- DeviceEvent) {
+	defer close(events)
+
+	type probeResult struct {
+		port string
+		gen  uint64
+		dp   DevicePort
+		err  error
+	}
+	probed := make(chan probeResult)
+	// Ports seen in the last scan; nil for the ones not (yet) identified
+	seen := make(map[string]*DevicePort)
+	// Probe in flight per port. A port removed and added again while it's
+	// probed gets a new probe, and the result of the old one is dropped.
+	probing := make(map[string]uint64)
+	var gen uint64
+	// Ports whose probe failed: time of the next probe and current delay
+	type retry struct {
+		next  time.Time
+		delay time.Duration
+	}
+	retries := make(map[string]*retry)
+
+	probe := func(port string) {
+		gen++
+		probing[port] = gen
+		go func(port string, gen uint64) {
+			dp, err := probePort(port, probeTimeout)
+			select {
+			case probed <- probeResult{port, gen, dp, err}:
+			case <-ctx.Done():
+			}
+		}(port, gen)
+	}
+
+	send := func(ev DeviceEvent) bool {
+		select {
+		case events <- ev:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		ports, _ := ListPorts()
+		present := make(map[string]bool)
+		for _, port := range ports {
+			present[port] = true
+			if _, ok := seen[port]; ok {
+				if r := retries[port]; r != nil && probing[port] == 0 && !time.Now().Before(r.next) {
+					probe(port)
+				}
+				continue
+			}
+			seen[port] = nil
+			if usb, err := ReadUSBInfo(port); err == nil && !usb.LikelyOpenDAQ() {
+				continue
+			}
+			probe(port)
+		}
+		for port, dp := range seen {
+			if present[port] {
+				continue
+			}
+			delete(seen, port)
+			delete(probing, port)
+			delete(retries, port)
+			if dp != nil && !send(DeviceEvent{DeviceDetached, *dp}) {
+				return
+			}
+		}
+
+		for wait := true; wait; {
+			select {
+			case r := <-probed:
+				// Ignore the results of ports removed while probing
+				if probing[r.port] != r.gen {
+					continue
+				}
+				delete(probing, r.port)
+				if r.err != nil {
+					rt := retries[r.port]
+					if rt == nil {
+						rt = &retry{delay: interval}
+						retries[r.port] = rt
+					} else {
+						rt.delay *= 2
+						if rt.delay > maxProbeRetry {
+							rt.delay = maxProbeRetry
+						}
+					}
+					rt.next = time.Now().Add(rt.delay)
+					continue
+				}
+				delete(retries, r.port)
+				dp := r.dp
+				seen[r.port] = &dp
+				if !send(DeviceEvent{DeviceAttached, dp}) {
+					return
+				}
+			case <-ticker.C:
+				wait = false
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
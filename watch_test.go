@@ -0,0 +1,122 @@
+package godaq
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchDevices(t *testing.T) {
+	defer fakeDeviceTree(t)()
+	defer func() { probeFunc = ProbePort }()
+	acm := filepath.Join(devRoot, "ttyACM0")
+	probeFunc = func(port string, timeout time.Duration) (DevicePort, error) {
+		if port == acm {
+			return DevicePort{Port: port}, errors.New("no answer")
+		}
+		return DevicePort{Model: ModelMId, Port: port, Serial: "0042"}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := WatchDevices(ctx, time.Millisecond)
+
+	ev := <-events
+	assert.Equal(t, DeviceAttached, ev.Kind)
+	assert.Equal(t, filepath.Join(devRoot, "ttyUSB0"), ev.Device.Port)
+	assert.Equal(t, "0042", ev.Device.Serial)
+
+	os.Remove(filepath.Join(devRoot, "ttyUSB0"))
+	ev = <-events
+	assert.Equal(t, DeviceDetached, ev.Kind)
+	assert.Equal(t, "0042", ev.Device.Serial)
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchDevicesReplug(t *testing.T) {
+	defer fakeDeviceTree(t)()
+	defer func() { probeFunc = ProbePort }()
+	usb0 := filepath.Join(devRoot, "ttyUSB0")
+	started := make(chan struct{})
+	release := make(chan struct{})
+	probeFunc = func(port string, timeout time.Duration) (DevicePort, error) {
+		started <- struct{}{}
+		<-release
+		return DevicePort{Model: ModelMId, Port: port, Serial: "0042"}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := WatchDevices(ctx, time.Millisecond)
+
+	// The port disappears and comes back while it's probed
+	<-started
+	os.Remove(usb0)
+	time.Sleep(20 * time.Millisecond)
+	assert.Nil(t, ioutil.WriteFile(usb0, nil, 0644))
+	<-started
+	release <- struct{}{}
+	release <- struct{}{}
+
+	ev := <-events
+	assert.Equal(t, DeviceAttached, ev.Kind)
+	assert.Equal(t, usb0, ev.Device.Port)
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event %+v", ev)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchDevicesRetry(t *testing.T) {
+	defer fakeDeviceTree(t)()
+	defer func() { probeFunc = ProbePort }()
+	usb0 := filepath.Join(devRoot, "ttyUSB0")
+	probes := make(chan struct{}, 10)
+	probeFunc = func(port string, timeout time.Duration) (DevicePort, error) {
+		probes <- struct{}{}
+		// Busy on the first probes
+		if len(probes) < 3 {
+			return DevicePort{Port: port}, &BusyError{Port: port}
+		}
+		return DevicePort{Model: ModelMId, Port: port, Serial: "0042"}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := WatchDevices(ctx, time.Millisecond)
+	select {
+	case ev := <-events:
+		assert.Equal(t, DeviceEvent{DeviceAttached, DevicePort{Model: ModelMId, Port: usb0, Serial: "0042"}}, ev)
+	case <-time.After(5 * time.Second):
+		t.Fatal("port not probed again")
+	}
+	assert.Len(t, probes, 3)
+
+	cancel()
+	for range events {
+	}
+}
+
+func TestWatchDevicesInterval(t *testing.T) {
+	defer fakeDeviceTree(t)()
+	defer func() { probeFunc = ProbePort }()
+	probeFunc = func(port string, timeout time.Duration) (DevicePort, error) {
+		return DevicePort{Port: port}, errors.New("No answer")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	events := WatchDevices(ctx, 0)
+	cancel()
+	for range events {
+	}
+}
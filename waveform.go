@@ -0,0 +1,186 @@
+// Copyright 2016 The Godaq Authors. All rights reserved
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package godaq
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+const (
+	// Maximum number of points loaded by each SIGNAL_LOAD command
+	signalChunkLen = 60
+
+	// Experiment mode of a channel that outputs the signal buffer
+	modeAnalogOut = 1
+
+	// Limits of the burst period. BURST_CREATE sends the period as a u32,
+	// but the firmware only accepts periods up to 65535 µs.
+	minBurstPeriod = 100 * time.Microsecond
+	maxBurstPeriod = 0xffff * time.Microsecond
+)
+
+var (
+	ErrSignalTooLong = errors.New("Waveform longer than the signal buffer")
+	ErrEmptySignal   = errors.New("Empty waveform")
+	ErrInvalidPeriod = errors.New("Waveform period out of range")
+	ErrInvalidPoints = errors.New("Invalid number of points")
+)
+
+// Load a waveform (in volts) into the signal buffer of the device and play
+// it at output n, one point every period. If repeat is false the waveform is
+// played only once, otherwise it is repeated until StopWaveform is called.
+// Every point is checked against the output limits and the interlocks.
+// Once started, the voltage of the output is unknown, so it must be set
+// again before ramping it.
+func (daq *OpenDAQ) PlayWaveform(n uint, volts []float32, period time.Duration, repeat bool) error {
+	if n < 1 || n > daq.NOutputs {
+		return ErrInvalidOutput
+	}
+	if period < minBurstPeriod || period > maxBurstPeriod {
+		return ErrInvalidPeriod
+	}
+	return daq.exec(PriorityOutput, func(d *OpenDAQ) error {
+		checked := make(map[float32]bool)
+		for _, v := range volts {
+			if checked[v] {
+				continue
+			}
+			if err := d.checkInterlocks(Change{Kind: ChangeAnalog, N: n, Volts: v}); err != nil {
+				return err
+			}
+			checked[v] = true
+		}
+		if err := d.loadSignal(n, volts); err != nil {
+			return err
+		}
+
+		us := uint32(period / time.Microsecond)
+		if _, err := d.call(BURST_CREATE, us); err != nil {
+			return err
+		}
+		if _, err := d.call(CHANNEL_CFG, 1, modeAnalogOut, n, 0, 0, 1); err != nil {
+			return err
+		}
+		npoints := len(volts)
+		if repeat {
+			npoints = 0
+		}
+		if _, err := d.call(CHANNEL_SETUP, 1, npoints, repeat); err != nil {
+			return err
+		}
+		_, err := d.call(STREAM_START)
+		d.forgetOutput(n)
+		return err
+	})
+}
+
+// Stop the waveform generation
+func (daq *OpenDAQ) StopWaveform() error {
+	_, err := daq.call(STREAM_STOP)
+	return err
+}
+
+// Convert a waveform to DAC values and write it into the signal buffer
+func (daq *OpenDAQ) loadSignal(n uint, volts []float32) error {
+	if len(volts) == 0 {
+		return ErrEmptySignal
+	}
+	if uint(len(volts)) > daq.NSignalPoints {
+		return ErrSignalTooLong
+	}
+	for _, v := range volts {
+		if err := daq.checkAnalog(n, v); err != nil {
+			return err
+		}
+	}
+	for start := 0; start < len(volts); start += signalChunkLen {
+		end := start + signalChunkLen
+		if end > len(volts) {
+			end = len(volts)
+		}
+		var data []byte
+		for _, v := range volts[start:end] {
+			data = append(data, toBytes(int16(daq.voltsToDac(v, n)))...)
+		}
+		if _, err := daq.call(SIGNAL_LOAD, start, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Return one period of a sine wave of n points between vmin and vmax
+func SineWave(n int, vmin, vmax float32) ([]float32, error) {
+	if n < 0 {
+		return nil, ErrInvalidPoints
+	}
+	amp, offs := (vmax-vmin)/2, (vmax+vmin)/2
+	wave := make([]float32, n)
+	for i := range wave {
+		wave[i] = offs + amp*float32(math.Sin(2*math.Pi*float64(i)/float64(n)))
+	}
+	return wave, nil
+}
+
+// Return one period of a square wave of n points: the first half at vmax and
+// the second half at vmin
+func SquareWave(n int, vmin, vmax float32) ([]float32, error) {
+	if n < 0 {
+		return nil, ErrInvalidPoints
+	}
+	wave := make([]float32, n)
+	for i := range wave {
+		if i < n/2 {
+			wave[i] = vmax
+		} else {
+			wave[i] = vmin
+		}
+	}
+	return wave, nil
+}
+
+// Return one period of a triangle wave of n points rising from vmin to vmax
+// and falling back
+func TriangleWave(n int, vmin, vmax float32) ([]float32, error) {
+	if n < 0 {
+		return nil, ErrInvalidPoints
+	}
+	wave := make([]float32, n)
+	for i := range wave {
+		x := 2 * float32(i) / float32(n)
+		if x > 1 {
+			x = 2 - x
+		}
+		wave[i] = vmin + (vmax-vmin)*x
+	}
+	return wave, nil
+}
+
+// Return a linear ramp of n points from vmin to vmax (both included)
+func RampWave(n int, vmin, vmax float32) ([]float32, error) {
+	if n < 0 {
+		return nil, ErrInvalidPoints
+	}
+	wave := make([]float32, n)
+	if n == 1 {
+		wave[0] = vmin
+		return wave, nil
+	}
+	for i := range wave {
+		wave[i] = vmin + (vmax-vmin)*float32(i)/float32(n-1)
+	}
+	return wave, nil
+}
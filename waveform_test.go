@@ -0,0 +1,102 @@
+package godaq
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSineWave(t *testing.T) {
+	wave, err := SineWave(4, -1, 3)
+	assert.Nil(t, err)
+	assert.Len(t, wave, 4)
+	assert.InDelta(t, 1, wave[0], 1e-6)
+	assert.InDelta(t, 3, wave[1], 1e-6)
+	assert.InDelta(t, 1, wave[2], 1e-6)
+	assert.InDelta(t, -1, wave[3], 1e-6)
+}
+
+func TestSquareWave(t *testing.T) {
+	wave, err := SquareWave(4, 0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []float32{2, 2, 0, 0}, wave)
+}
+
+func TestTriangleWave(t *testing.T) {
+	wave, err := TriangleWave(4, 0, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []float32{0, 1, 2, 1}, wave)
+}
+
+func TestRampWave(t *testing.T) {
+	wave, err := RampWave(3, 1, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []float32{1, 2, 3}, wave)
+	wave, _ = RampWave(1, 1, 3)
+	assert.Equal(t, []float32{1}, wave)
+	wave, _ = RampWave(0, 1, 3)
+	assert.Empty(t, wave)
+}
+
+func TestWaveNegativePoints(t *testing.T) {
+	for _, f := range []func(int, float32, float32) ([]float32, error){
+		SineWave, SquareWave, TriangleWave, RampWave,
+	} {
+		_, err := f(-1, 0, 1)
+		assert.Equal(t, ErrInvalidPoints, err)
+	}
+}
+
+func TestLoadSignalLimits(t *testing.T) {
+	daq := &OpenDAQ{conn: &conn{HwFeatures: NewModelM().GetFeatures()}}
+	assert.Equal(t, ErrEmptySignal, daq.loadSignal(1, nil))
+	assert.Equal(t, ErrSignalTooLong, daq.loadSignal(1, make([]float32, 401)))
+}
+
+func TestPlayWaveform(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	assert.Nil(t, daq.SetAnalog(1, 1))
+	wave, _ := RampWave(100, 0, 2)
+	assert.Nil(t, daq.PlayWaveform(1, wave, time.Millisecond, false))
+	assert.Len(t, sim.sent(SIGNAL_LOAD), 2)
+	assert.Len(t, sim.sent(STREAM_START), 1)
+
+	// The output voltage is no longer known
+	assert.False(t, daq.Snapshot().Analog[0].Known)
+	assert.Equal(t, ErrUnknownOutput, daq.RampAnalog(context.Background(), 1, 0, 1, 0.1))
+}
+
+func TestPlayWaveformChecks(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	assert.Nil(t, daq.SetOutputLimits(1, 0, 1))
+	wave, _ := RampWave(10, 0, 2)
+	assert.IsType(t, &LimitError{}, daq.PlayWaveform(1, wave, time.Millisecond, false))
+
+	assert.Nil(t, daq.SetOutputLimits(1, -4, 4))
+	errHigh := errors.New("Too high")
+	daq.AddInterlock("max 1.5 V", func(daq *OpenDAQ, c Change) error {
+		if c.Kind == ChangeAnalog && c.Volts > 1.5 {
+			return errHigh
+		}
+		return nil
+	})
+	err := daq.PlayWaveform(1, wave, time.Millisecond, false)
+	assert.IsType(t, &InterlockError{}, err)
+	assert.True(t, errors.Is(err, errHigh))
+	assert.Empty(t, sim.sent(SIGNAL_LOAD))
+	assert.Empty(t, sim.sent(STREAM_START))
+}
+
+func TestPlayWaveformPeriod(t *testing.T) {
+	daq, sim := newSimDAQ(t, WithIdentityCalib())
+	wave := []float32{0, 1}
+	assert.Equal(t, ErrInvalidPeriod, daq.PlayWaveform(1, wave, 99*time.Microsecond, false))
+	assert.Equal(t, ErrInvalidPeriod, daq.PlayWaveform(1, wave, 0x10000*time.Microsecond, false))
+	assert.Empty(t, sim.sent(BURST_CREATE))
+
+	assert.Nil(t, daq.PlayWaveform(1, wave, 0xffff*time.Microsecond, true))
+	assert.Equal(t, []Message{{BURST_CREATE, []byte{0, 0, 0xff, 0xff}}}, sim.sent(BURST_CREATE))
+}